@@ -2,21 +2,32 @@ package gokenizer
 
 import (
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/jesperkha/gokenizer/stringiter"
 )
 
-func isLetter(c byte) bool {
-	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+func isLetter(r rune) bool {
+	return unicode.IsLetter(r)
+}
+
+func isWhitespace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
 }
 
 func isNumber(c byte) bool {
 	return c >= '0' && c <= '9'
 }
 
-func isSymbol(c byte) bool {
+func isSymbol(r rune) bool {
 	s := "!\"#$&%'()*+,-./:;<=>?@[]\\^_`{}|~¤§£"
-	return strings.Contains(s, string(c))
+	if strings.ContainsRune(s, r) {
+		return true
+	}
+
+	// Non-ascii punctuation and symbols, such as « and €
+	return r >= utf8.RuneSelf && r != utf8.RuneError && (unicode.IsPunct(r) || unicode.IsSymbol(r))
 }
 
 func isBase64(c byte) bool {
@@ -29,33 +40,61 @@ func isHex(c byte) bool {
 	return strings.Contains(s, string(c))
 }
 
-var classes = map[string]matcherFunc{
-	"any": func(iter *stringiter.StringIter) Token {
-		return checkFuncToMatchFunc("any", func(b byte) bool {
-			return true
-		})(iter)
-	},
+// Checkers of the built-in classes matching a run of characters. These can
+// be given a length, such as {number 4} or {hex 2,8}.
+var runCheckers = map[string]charCheckerFunc{
+	"any":  runeChecker(func(r rune) bool { return true }),
+	"ws":   runeChecker(isWhitespace),
+	"text": runeChecker(func(r rune) bool { return !isWhitespace(r) }),
+	"word": runeChecker(isLetter),
+	"var": runeChecker(func(r rune) bool {
+		return isLetter(r) || r == '$' || r == '_'
+	}),
+	"base64": byteChecker(isBase64),
+	"hex":    byteChecker(isHex),
+	"number": byteChecker(isNumber),
+	"float": byteChecker(func(b byte) bool {
+		return isNumber(b) || b == '.'
+	}),
+}
 
-	"ws": func(iter *stringiter.StringIter) Token {
-		c := ""
-		for !iter.Eof() {
-			b := iter.Peek()
-			if b == ' ' || b == '\t' || b == '\n' || b == '\r' {
-				c += iter.Consume()
-				continue
+// Built-in classes that must be given a string, such as {until ";"}.
+var stringClasses = map[string]func(s string) matcherFunc{
+	// Everything up to, but not including, s
+	"until": func(s string) matcherFunc {
+		return singleMatchFunc(func(iter *stringiter.StringIter) Token {
+			start := iter.Pos()
+			for !iter.Eof() && iter.Slice(iter.Pos(), iter.Pos()+len(s)) != s {
+				iter.ConsumeRune()
 			}
-			break
-		}
-		return Token{matched: true, Lexeme: c}
+
+			if iter.Eof() || iter.Pos() == start {
+				return Token{matched: false}
+			}
+
+			return Token{
+				Lexeme:  iter.Slice(start, iter.Pos()),
+				matched: true,
+			}
+		})
 	},
 
-	"text": func(iter *stringiter.StringIter) Token {
-		return checkFuncToMatchFunc("text", func(b byte) bool {
-			return !(b == ' ' || b == '\t' || b == '\n' || b == '\r')
-		})(iter)
+	// Any characters not in s
+	"not": func(s string) matcherFunc {
+		return runeCheckFuncToMatchFunc("not", func(r rune) bool {
+			return !strings.ContainsRune(s, r)
+		})
 	},
+}
+
+var classes = map[string]matcherFunc{
+	"any": greedyMatchFunc("any", runCheckers["any"], 1, -1),
+
+	"ws": greedyMatchFunc("ws", runCheckers["ws"], 0, -1),
+
+	"text": greedyMatchFunc("text", runCheckers["text"], 1, -1),
 
-	"lbrace": func(iter *stringiter.StringIter) Token {
+	"lbrace": singleMatchFunc(func(iter *stringiter.StringIter) Token {
 		if iter.Peek() == '{' {
 			return Token{
 				Lexeme:  iter.Consume(),
@@ -64,9 +103,9 @@ var classes = map[string]matcherFunc{
 		}
 
 		return Token{matched: false}
-	},
+	}),
 
-	"rbrace": func(iter *stringiter.StringIter) Token {
+	"rbrace": singleMatchFunc(func(iter *stringiter.StringIter) Token {
 		if iter.Peek() == '}' {
 			return Token{
 				Lexeme:  iter.Consume(),
@@ -75,58 +114,39 @@ var classes = map[string]matcherFunc{
 		}
 
 		return Token{matched: false}
-	},
+	}),
 
-	"word": func(iter *stringiter.StringIter) Token {
-		return checkFuncToMatchFunc("word", func(b byte) bool {
-			return isLetter(b)
-		})(iter)
-	},
+	"word": greedyMatchFunc("word", runCheckers["word"], 1, -1),
 
-	"var": func(iter *stringiter.StringIter) Token {
-		return checkFuncToMatchFunc("var", func(b byte) bool {
-			return isLetter(b) || b == '$' || b == '_'
-		})(iter)
-	},
+	"var": greedyMatchFunc("var", runCheckers["var"], 1, -1),
 
-	"base64": func(iter *stringiter.StringIter) Token {
-		return checkFuncToMatchFunc("base64", func(b byte) bool {
-			return isBase64(b)
-		})(iter)
-	},
+	"base64": greedyMatchFunc("base64", runCheckers["base64"], 1, -1),
 
-	"hex": func(iter *stringiter.StringIter) Token {
-		return checkFuncToMatchFunc("hex", func(b byte) bool {
-			return isHex(b)
-		})(iter)
-	},
+	"hex": greedyMatchFunc("hex", runCheckers["hex"], 1, -1),
 
-	"number": func(iter *stringiter.StringIter) Token {
-		return checkFuncToMatchFunc("number", func(b byte) bool {
-			return isNumber(b)
-		})(iter)
-	},
+	"number": greedyMatchFunc("number", runCheckers["number"], 1, -1),
 
-	"float": func(iter *stringiter.StringIter) Token {
-		return checkFuncToMatchFunc("float", func(b byte) bool {
-			return isNumber(b) || b == '.'
-		})(iter)
-	},
+	"float": greedyMatchFunc("float", runCheckers["float"], 1, -1),
 
-	"symbol": func(iter *stringiter.StringIter) Token {
-		if isSymbol(iter.Peek()) {
+	"symbol": singleMatchFunc(func(iter *stringiter.StringIter) Token {
+		if r, _ := iter.PeekRune(); isSymbol(r) {
 			return Token{
-				Lexeme:  iter.Consume(),
+				Lexeme:  iter.ConsumeRune(),
 				matched: true,
 			}
 		}
 
 		return Token{matched: false}
-	},
+	}),
 
-	"line": func(iter *stringiter.StringIter) Token {
+	"line": singleMatchFunc(func(iter *stringiter.StringIter) Token {
 		if iter.Seek('\n') {
-			line := iter.Consume()
+			// Consume would take the newline if the line is empty
+			line := ""
+			if iter.Peek() != '\n' {
+				line = iter.Consume()
+			}
+
 			iter.Consume() // Consume newline to prevent infinite loop
 
 			return Token{
@@ -136,37 +156,114 @@ var classes = map[string]matcherFunc{
 		}
 
 		return Token{matched: false}
-	},
+	}),
 
-	"char": func(iter *stringiter.StringIter) Token {
-		if isLetter(iter.Peek()) {
+	"char": singleMatchFunc(func(iter *stringiter.StringIter) Token {
+		if r, _ := iter.PeekRune(); isLetter(r) {
 			return Token{
-				Lexeme:  iter.Consume(),
+				Lexeme:  iter.ConsumeRune(),
 				matched: true,
 			}
 		}
 
 		return Token{matched: false}
-	},
+	}),
+
+	"string": singleMatchFunc(matchString),
+
+	"parens": singleMatchFunc(balancedMatcher('(', ')')),
+
+	"brackets": singleMatchFunc(balancedMatcher('[', ']')),
+
+	"braces": singleMatchFunc(balancedMatcher('{', '}')),
+}
+
+// Returns the matcher of a built-in class given an argument. Errors are of
+// type *PatternError.
+func argClass(name string, args classArgs) (mf matcherFunc, err error) {
+	if f, ok := stringClasses[name]; ok {
+		if args.isLength || args.str == "" {
+			return mf, patternError(ErrInvalidArgument, -1, "class '%s' needs a non-empty string", name)
+		}
+
+		return f(args.str), err
+	}
+
+	if check, ok := runCheckers[name]; ok {
+		if !args.isLength {
+			return mf, patternError(ErrInvalidArgument, -1, "class '%s' needs a length", name)
+		}
+
+		return greedyMatchFunc(name, check, args.min, args.max), err
+	}
+
+	if _, ok := classes[name]; !ok {
+		return mf, patternError(ErrUnknownClass, -1, "unknown class '%s'", name)
+	}
+
+	return mf, patternError(ErrInvalidArgument, -1, "class '%s' does not take arguments", name)
+}
+
+// Matches a string in double quotes. The lexeme is the content of the string.
+func matchString(iter *stringiter.StringIter) Token {
+	if iter.Peek() == '"' {
+		iter.Consume()
+
+		if iter.Seek('"') {
+			// Consumes string content, then terminating quote. Consume would
+			// take the quote if the string is empty.
+			str := ""
+			if iter.Peek() != '"' {
+				str = iter.Consume()
+			}
 
-	"string": func(iter *stringiter.StringIter) Token {
-		if iter.Peek() == '"' {
-			iter.Push()
 			iter.Consume()
+			return Token{
+				Lexeme:  str,
+				matched: true,
+			}
+		}
+	}
+
+	return Token{matched: false}
+}
 
-			if iter.Seek('"') {
-				// Consumes string content, then terminating quote
-				str := iter.Consume()
-				iter.Consume()
+// Returns a function matching text enclosed by open and close, including
+// them. Nested pairs must be balanced, and delimiters inside strings, as
+// matched by the string class, are ignored.
+func balancedMatcher(open byte, close byte) func(iter *stringiter.StringIter) Token {
+	return func(iter *stringiter.StringIter) Token {
+		if iter.Peek() != open {
+			return Token{matched: false}
+		}
+
+		start := iter.Pos()
+		depth := 0
+
+		for !iter.Eof() {
+			switch iter.Peek() {
+			case '"':
+				// An unterminated quote is just a character
+				if !matchString(iter).matched {
+					iter.Restore()
+				}
+				continue
+			case open:
+				depth++
+			case close:
+				depth--
+			}
+
+			iter.Consume()
+
+			if depth == 0 {
 				return Token{
-					Lexeme:  str,
+					Lexeme:  iter.Slice(start, iter.Pos()),
 					matched: true,
 				}
 			}
-
-			iter.Pop()
 		}
 
 		return Token{matched: false}
-	},
+	}
 }
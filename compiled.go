@@ -0,0 +1,455 @@
+package gokenizer
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/jesperkha/gokenizer/stringiter"
+)
+
+// Compiled is an immutable tokenizer created by Tokenizer.Compile(). It is
+// safe for concurrent use by multiple goroutines, as long as the pattern
+// callbacks and unmatched handler are too.
+type Compiled struct {
+	patterns   []string
+	matchFuncs []matcherFunc
+	callbacks  []func(Token) error
+	classes    map[string]matcherFunc
+	priorities []int
+	lazyLines  bool
+	strict     bool
+	longest    bool
+	maxDepth   int
+	unmatched  func(Token) error
+}
+
+// Compiles the classes and patterns of t. Returns every error found, of
+// type *PatternError or *ClassError.
+func (t *Tokenizer) compile() (*Compiled, []error) {
+	c := &Compiled{
+		patterns:   slices.Clone(t.patterns),
+		priorities: slices.Clone(t.priorities),
+		callbacks:  slices.Clone(t.callbacks),
+		classes:    make(map[string]matcherFunc),
+		lazyLines:  t.lazyLines,
+		strict:     t.strict,
+		longest:    t.longest,
+		maxDepth:   t.maxDepth,
+		unmatched:  t.unmatched,
+	}
+
+	// All classes are known before any is compiled, so patterns can refer
+	// to classes defined after them. Pattern classes are set below.
+	for _, def := range t.classes {
+		c.classes[def.name] = def.mf
+	}
+
+	errs := []error{}
+	grammar := make(map[string][][]patternElem)
+
+	for _, def := range t.classes {
+		if def.mf != nil {
+			continue
+		}
+
+		funcs := []matcherFunc{}
+		for _, pattern := range def.patterns {
+			mf, elems, err := c.createMatcherFunc(pattern)
+			if err != nil {
+				if perr, ok := err.(*PatternError); ok {
+					perr.Class = def.name
+				}
+
+				errs = append(errs, err)
+				continue
+			}
+
+			funcs = append(funcs, mf)
+			grammar[def.name] = append(grammar[def.name], elems)
+		}
+
+		c.classes[def.name] = classMatchFunc(def.name, funcs)
+	}
+
+	patternElems := [][]patternElem{}
+	for _, pattern := range t.patterns {
+		mf, elems, err := c.createMatcherFunc(pattern)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		c.matchFuncs = append(c.matchFuncs, mf)
+		patternElems = append(patternElems, elems)
+	}
+
+	// Patterns that failed above are left out of the checks below
+	nullable := nullableClasses(grammar)
+	order := []string{}
+	for _, def := range t.classes {
+		order = append(order, def.name)
+	}
+
+	errs = append(errs, leftRecursionErrors(order, grammar, nullable)...)
+
+	for i, elems := range patternElems {
+		if elemsNullable(elems, nullable) {
+			errs = append(errs, &PatternError{
+				Pattern: c.patterns[i],
+				Offset:  -1,
+				Kind:    ErrEmptyMatch,
+				Msg:     "pattern matches the empty string",
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return c, nil
+}
+
+// Returns a function that matches the given pattern, and the parsed pattern.
+// Errors are of type *PatternError.
+func (c *Compiled) createMatcherFunc(pattern string) (mf matcherFunc, elems []patternElem, err error) {
+	elems, err = parsePattern(pattern)
+	if err != nil {
+		return mf, elems, err
+	}
+
+	mf, err = c.elemsMatchFunc(pattern, elems)
+	return mf, elems, err
+}
+
+// Returns a function that matches the parsed elements of pattern in
+// sequence. Errors are of type *PatternError.
+func (c *Compiled) elemsMatchFunc(pattern string, elems []patternElem) (mf matcherFunc, err error) {
+	funcs := []matcherFunc{}
+	for _, e := range elems {
+		if e.ref.name == "" {
+			funcs = append(funcs, literalMatcherFunc(e.literal))
+			continue
+		}
+
+		// Character sets are repeated by the matcher itself, so a run of
+		// characters is a single value
+		if e.ref.set != nil {
+			funcs = append(funcs, greedyMatchFunc(e.ref.name, runeChecker(e.ref.set), e.ref.min, e.ref.max))
+			continue
+		}
+
+		var f matcherFunc
+
+		// Inline groups match like an unnamed class
+		if e.ref.group != nil {
+			if f, err = c.groupMatchFunc(pattern, e.ref.group); err != nil {
+				return mf, err
+			}
+		} else if f, err = c.getClass(e.ref.name, e.ref.args); err != nil {
+			if perr, ok := err.(*PatternError); ok {
+				perr.Pattern = pattern
+				perr.Offset = e.ref.offset
+			}
+			return mf, err
+		}
+
+		if e.ref.min != 1 || e.ref.max != 1 {
+			f = repeatMatchFunc(f, e.ref.min, e.ref.max)
+		}
+
+		funcs = append(funcs, f)
+	}
+
+	return sequenceMatchFunc(funcs, elems), err
+}
+
+// Returns a function that matches any of the branches of an inline group,
+// tried in order. Errors are of type *PatternError.
+func (c *Compiled) groupMatchFunc(pattern string, group [][]patternElem) (mf matcherFunc, err error) {
+	funcs := []matcherFunc{}
+	for _, branch := range group {
+		f, err := c.elemsMatchFunc(pattern, branch)
+		if err != nil {
+			return mf, err
+		}
+
+		funcs = append(funcs, f)
+	}
+
+	return classMatchFunc("", funcs), err
+}
+
+// Returns the matcher of a built-in or user class, given its arguments if
+// any. User classes are looked up when matching, as they may not be
+// compiled yet, and may be recursive. The match is aborted if they are
+// nested deeper than the max depth. Errors are of type *PatternError.
+func (c *Compiled) getClass(name string, args *classArgs) (mf matcherFunc, err error) {
+	_, isUserClass := c.classes[name]
+
+	switch {
+	case args != nil && isUserClass:
+		return mf, patternError(ErrInvalidArgument, -1, "class '%s' does not take arguments", name)
+	case args != nil:
+		return argClass(name, *args)
+	}
+
+	if mf, ok := classes[name]; ok {
+		return mf, err
+	}
+
+	if _, ok := stringClasses[name]; ok {
+		return mf, patternError(ErrInvalidArgument, -1, "class '%s' needs a string argument", name)
+	}
+
+	if !isUserClass {
+		return mf, patternError(ErrUnknownClass, -1, "unknown class '%s'", name)
+	}
+
+	return func(iter *matchIter, k func(Token) bool) bool {
+		if iter.err != nil {
+			return false
+		}
+
+		// A max depth of 0 means no limit
+		if iter.maxDepth > 0 && iter.depth == iter.maxDepth {
+			iter.err = fmt.Errorf("class '%s' nested more than %d times: %w", name, iter.maxDepth, ErrMaxDepth)
+			return false
+		}
+
+		iter.depth++
+		matched := c.classes[name](iter, func(tok Token) bool {
+			// The class is done once the rest of the pattern is matched
+			iter.depth--
+			accepted := k(tok)
+			iter.depth++
+			return accepted
+		})
+		iter.depth--
+
+		return matched
+	}, err
+}
+
+// Returns the set of classes that can match the empty string. Built-in
+// classes are tested directly, pattern classes are nullable if any of their
+// patterns only has nullable elements.
+func nullableClasses(grammar map[string][][]patternElem) map[string]bool {
+	nullable := make(map[string]bool)
+	for name, mf := range classes {
+		nullable[name] = matchesEmpty(mf)
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for name, patterns := range grammar {
+			if nullable[name] {
+				continue
+			}
+
+			if slices.ContainsFunc(patterns, func(elems []patternElem) bool {
+				return elemsNullable(elems, nullable)
+			}) {
+				nullable[name] = true
+				changed = true
+			}
+		}
+	}
+
+	return nullable
+}
+
+// Returns true if every element can match the empty string.
+func elemsNullable(elems []patternElem, nullable map[string]bool) bool {
+	for _, e := range elems {
+		if !e.nullable(nullable) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns a *ClassError for each cycle of classes that can refer to
+// themselves without consuming any input, as matching them would never end.
+// Classes are visited in the given order, so errors are reported in a
+// stable order.
+func leftRecursionErrors(order []string, grammar map[string][][]patternElem, nullable map[string]bool) (errs []error) {
+	// The classes each class can start by matching
+	first := make(map[string][]string)
+	for name, patterns := range grammar {
+		for _, elems := range patterns {
+			first[name] = append(first[name], firstClasses(elems, nullable)...)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int)
+	path := []string{}
+
+	var visit func(name string)
+	visit = func(name string) {
+		switch state[name] {
+		case visited:
+			return
+		case visiting:
+			cycle := append(slices.Clone(path[slices.Index(path, name):]), name)
+			errs = append(errs, &ClassError{
+				Class: name,
+				Kind:  ErrLeftRecursion,
+				Msg:   "left recursion: " + strings.Join(cycle, " -> "),
+			})
+			return
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+
+		for _, next := range first[name] {
+			visit(next)
+		}
+
+		path = path[:len(path)-1]
+		state[name] = visited
+	}
+
+	for _, name := range order {
+		visit(name)
+	}
+
+	return errs
+}
+
+// Run tokenizer on given input string. See Tokenizer.Run().
+func (c *Compiled) Run(s string) error {
+	if len(c.callbacks) == 0 && !c.strict && c.unmatched == nil {
+		return nil
+	}
+
+	return c.run(c.Lex(s))
+}
+
+// RunReader runs the tokenizer on the input read from r. See
+// Tokenizer.RunReader().
+func (c *Compiled) RunReader(r io.Reader) error {
+	return c.run(c.LexReader(r))
+}
+
+// Matches returns true if s matches the given pattern. See
+// Tokenizer.Matches().
+func (c *Compiled) Matches(s string, pattern string) (matched bool, err error) {
+	src := stringiter.New(s)
+	iter := c.newMatchIter(&src)
+
+	mf, _, err := c.createMatcherFunc(pattern)
+	if err != nil {
+		return matched, err
+	}
+
+	matched = mf(&iter, func(Token) bool {
+		return iter.Eof()
+	})
+
+	if iter.err != nil {
+		return false, fmt.Errorf("gokenizer: %w", iter.err)
+	}
+
+	return matched, err
+}
+
+// Consumes all tokens from the lexer. Returns the first error.
+func (c *Compiled) run(lex *Lexer) error {
+	for {
+		if _, err := lex.Next(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+// Returns a match iterator for src with the limits of c.
+func (c *Compiled) newMatchIter(src *stringiter.StringIter) matchIter {
+	return matchIter{
+		StringIter: src,
+		maxDepth:   c.maxDepth,
+	}
+}
+
+// Returns the token of the first matching pattern, or the longest in longest
+// match mode, and the index of its callback. The index is -1 if no pattern
+// matched. Returns an error if the match was aborted.
+func (c *Compiled) matchNext(src *stringiter.StringIter) (token Token, callbackIdx int, err error) {
+	iter := c.newMatchIter(src)
+	pos := iter.Pos()
+	best := Token{}
+	end := pos
+	callbackIdx = -1
+
+	for idx, mf := range c.matchFuncs {
+		result := Token{}
+
+		// Empty matches are rejected as they would never advance the iterator
+		matched := mf(&iter, func(tok Token) bool {
+			result = tok
+			return iter.Pos() > pos
+		})
+
+		if iter.err != nil {
+			line, col := src.LineColAt(pos)
+			return token, -1, fmt.Errorf("gokenizer: line %d, column %d: %w", line, col, iter.err)
+		}
+
+		if !matched {
+			continue
+		}
+
+		if !c.longest {
+			return c.newToken(src, pos, iter.Pos(), result.Children), idx, nil
+		}
+
+		// Ties go to the highest priority, then the first defined
+		if callbackIdx == -1 || iter.Pos() > end || (iter.Pos() == end && c.priorities[idx] > c.priorities[callbackIdx]) {
+			best, end, callbackIdx = result, iter.Pos(), idx
+		}
+
+		iter.SetPos(pos)
+	}
+
+	if callbackIdx == -1 {
+		return token, callbackIdx, nil
+	}
+
+	iter.SetPos(end)
+	return c.newToken(src, pos, end, best.Children), callbackIdx, nil
+}
+
+// Returns a token for the source between start and end. Line info is set
+// for the token and its values unless LazyLineInfo() is used. It is always
+// set when reading from a reader, as it cannot be computed later.
+func (c *Compiled) newToken(iter *stringiter.StringIter, start int, end int, children []Token) Token {
+	lexeme := iter.Slice(start, end)
+
+	token := Token{
+		Pos:      start,
+		Lexeme:   lexeme,
+		Source:   iter.Source(),
+		Length:   len(lexeme),
+		Children: children,
+	}
+
+	if !c.lazyLines || iter.Streaming() {
+		token.setLineInfo(iter)
+	}
+
+	return token
+}
@@ -0,0 +1,68 @@
+package gokenizer
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Int returns the lexeme as a decimal integer, as matched by {number}.
+// Errors are of type *ConversionError.
+func (t Token) Int() (int, error) {
+	n, err := strconv.Atoi(t.Lexeme)
+	return n, t.conversionError("int", err)
+}
+
+// Float returns the lexeme as a floating point number, as matched by
+// {float}. Errors are of type *ConversionError.
+func (t Token) Float() (float64, error) {
+	f, err := strconv.ParseFloat(t.Lexeme, 64)
+	return f, t.conversionError("float", err)
+}
+
+// Bool returns the lexeme as a boolean, accepting the values of
+// strconv.ParseBool, such as "true" and "false". Errors are of type
+// *ConversionError.
+func (t Token) Bool() (bool, error) {
+	b, err := strconv.ParseBool(t.Lexeme)
+	return b, t.conversionError("bool", err)
+}
+
+// HexBytes returns the bytes of the lexeme decoded as hex, as matched by
+// {hex}. A leading #, as in color codes, is ignored. Errors are of type
+// *ConversionError.
+func (t Token) HexBytes() ([]byte, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(t.Lexeme, "#"))
+	return b, t.conversionError("hex bytes", err)
+}
+
+// Base64Bytes returns the bytes of the lexeme decoded as standard base64,
+// as matched by {base64}. The padding is optional, but must be correct if
+// given. Errors are of type *ConversionError.
+func (t Token) Base64Bytes() ([]byte, error) {
+	enc := base64.RawStdEncoding
+	if strings.HasSuffix(t.Lexeme, "=") {
+		enc = base64.StdEncoding
+	}
+
+	b, err := enc.DecodeString(t.Lexeme)
+	return b, t.conversionError("base64 bytes", err)
+}
+
+// Unquote returns the content of a double quoted string, which is the
+// lexeme of {string}, with Go escape sequences such as \n replaced. The
+// lexeme does not include the quotes. Errors are of type *ConversionError.
+func (t Token) Unquote() (string, error) {
+	s, err := strconv.Unquote(`"` + t.Lexeme + `"`)
+	return s, t.conversionError("string", err)
+}
+
+// Returns err as a *ConversionError for the token, or nil if err is nil.
+func (t Token) conversionError(typ string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &ConversionError{Token: t, Type: typ, Err: err}
+}
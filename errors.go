@@ -0,0 +1,127 @@
+package gokenizer
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of configuration errors. Use errors.Is to check the kind of an
+// error returned by Run() or Compile().
+var (
+	ErrSyntax          = errors.New("syntax error")
+	ErrUnknownClass    = errors.New("unknown class")
+	ErrInvalidName     = errors.New("invalid name")
+	ErrClassDefined    = errors.New("class already defined")
+	ErrNoPatterns      = errors.New("no patterns")
+	ErrEmptyPattern    = errors.New("empty pattern")
+	ErrEmptyMatch      = errors.New("pattern matches the empty string")
+	ErrNilCallback     = errors.New("nil callback")
+	ErrLeftRecursion   = errors.New("left recursion")
+	ErrInvalidArgument = errors.New("invalid class argument")
+)
+
+// ErrMaxDepth is returned by Run() when classes are nested deeper than the
+// limit set by Tokenizer.MaxDepth().
+var ErrMaxDepth = errors.New("maximum depth exceeded")
+
+// PatternError describes a malformed pattern.
+type PatternError struct {
+	Pattern string // The malformed pattern
+	Class   string // The class the pattern belongs to, empty for Pattern()
+	Offset  int    // Byte offset of the error in Pattern, -1 if not applicable
+	Kind    error  // One of the Err variables
+	Msg     string // Description of the error
+}
+
+func (e *PatternError) Error() string {
+	s := "gokenizer: "
+	if e.Class != "" {
+		s += fmt.Sprintf("class '%s': ", e.Class)
+	}
+
+	s += fmt.Sprintf("pattern '%s'", e.Pattern)
+	if e.Offset != -1 {
+		s += fmt.Sprintf(" at offset %d", e.Offset)
+	}
+
+	return s + ": " + e.Msg
+}
+
+func (e *PatternError) Unwrap() error {
+	return e.Kind
+}
+
+// Returns a *PatternError of the given kind. The pattern is set by
+// parsePattern.
+func patternError(kind error, offset int, format string, args ...any) *PatternError {
+	return &PatternError{
+		Offset: offset,
+		Kind:   kind,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// ClassError describes an invalid class definition.
+type ClassError struct {
+	Class string // Name of the class
+	Kind  error  // One of the Err variables
+	Msg   string // Description of the error
+}
+
+func (e *ClassError) Error() string {
+	return fmt.Sprintf("gokenizer: class '%s': %s", e.Class, e.Msg)
+}
+
+func (e *ClassError) Unwrap() error {
+	return e.Kind
+}
+
+// UnmatchedError is returned by Run() in strict mode when a character is
+// not matched by any pattern.
+type UnmatchedError struct {
+	Token Token // The unmatched character
+}
+
+func (e *UnmatchedError) Error() string {
+	line, col := e.Token.LineCol()
+	return fmt.Sprintf("gokenizer: unmatched input %q at line %d, column %d", e.Token.Lexeme, line, col)
+}
+
+// CallbackError wraps an error returned by a pattern callback or the
+// unmatched handler. The original error is available with errors.Unwrap.
+type CallbackError struct {
+	Token   Token  // The token given to the callback
+	Pattern string // The pattern that matched, empty for unmatched input
+	Err     error  // The error returned by the callback
+}
+
+func (e *CallbackError) Error() string {
+	line, col := e.Token.LineCol()
+	if e.Pattern == "" {
+		return fmt.Sprintf("gokenizer: unmatched input at line %d, column %d: %s", line, col, e.Err)
+	}
+
+	return fmt.Sprintf("gokenizer: pattern '%s' at line %d, column %d: %s", e.Pattern, line, col, e.Err)
+}
+
+func (e *CallbackError) Unwrap() error {
+	return e.Err
+}
+
+// ConversionError is returned by the conversion methods of Token, such as
+// Token.Int(), when the lexeme is not a valid value of the type. The error
+// from the strconv or encoding package is available with errors.Unwrap.
+type ConversionError struct {
+	Token Token  // The token that was converted
+	Type  string // The type converted to, such as "int"
+	Err   error  // The underlying error
+}
+
+func (e *ConversionError) Error() string {
+	line, col := e.Token.LineCol()
+	return fmt.Sprintf("gokenizer: cannot convert %q to %s at line %d, column %d: %s", e.Token.Lexeme, e.Type, line, col, e.Err)
+}
+
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
@@ -1,5 +1,5 @@
 module github.com/jesperkha/gokenizer
 
-go 1.22.1
+go 1.23
 
 require github.com/joho/godotenv v1.5.1
@@ -1,230 +1,463 @@
 package gokenizer
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"slices"
 
 	"github.com/jesperkha/gokenizer/stringiter"
 )
 
 type Tokenizer struct {
-	err        error
-	matchFuncs []matcherFunc
+	errs       []error
+	lazyLines  bool
+	strict     bool
+	longest    bool
+	maxDepth   int
+	unmatched  func(Token) error
+	patterns   []string
+	priorities []int
 	callbacks  []func(Token) error
-	classes    map[string]matcherFunc
+	classes    []classDef
+	compiled   *Compiled // Cached by Compile(), nil if changed since
+}
+
+// A user defined class. Classes are compiled together with the patterns,
+// so they can be used before they are defined.
+type classDef struct {
+	name     string
+	patterns []string    // Patterns of a class created by Class()
+	mf       matcherFunc // Matcher of a class created by ClassFunc()
+	optional bool        // The last pattern is the empty one added by ClassOptional()
+}
+
+// A string iterator with the state of the match in progress.
+type matchIter struct {
+	*stringiter.StringIter
+	depth    int   // Number of user classes currently being matched
+	maxDepth int   // Limit for depth, 0 for no limit
+	err      error // Set if the match was aborted
 }
 
 // Matches with the given string. The implementation is dynamically created
-// in createPattern.
-type matcherFunc func(iter *stringiter.StringIter) Token
+// in createPattern. For every possible match, in order of preference, the
+// continuation k is called with the iterator positioned after the match.
+// Returns true as soon as k accepts a match, leaving the iterator after it.
+// Otherwise the iterator is restored and false is returned.
+type matcherFunc func(iter *matchIter, k func(Token) bool) bool
 
 // Returns true if the character b is part of the class.
 type CheckerFunc func(b byte) bool
 
+// Returns true if the rune r is part of the class.
+type RuneCheckerFunc func(r rune) bool
+
+// Default limit for how deep classes can be nested in a match. See MaxDepth().
+const DefaultMaxDepth = 1000
+
 func New() Tokenizer {
 	return Tokenizer{
-		classes: make(map[string]matcherFunc),
+		maxDepth: DefaultMaxDepth,
 	}
 }
 
 // Pattern adds a new pattern to the tokenizer. If a match is found, the
 // callback function f is called. The callback may return an error which
-// will be returned by Run(). The patterns are matched by the order they
-// are defined in.
+// will be returned by Run(), wrapped in a *CallbackError. The patterns are
+// matched by the order they are defined in. A pattern must consume at least
+// one character, so patterns that can match the empty string are reported
+// as an error.
 func (t *Tokenizer) Pattern(pattern string, f func(Token) error) {
 	if pattern == "" {
-		t.setError(fmt.Errorf("empty pattern not allowed"))
+		t.addError(patternError(ErrEmptyPattern, -1, "empty pattern not allowed"))
 		return
 	}
 	if f == nil {
-		t.setError(fmt.Errorf("callback for pattern '%s' is nil", pattern))
+		t.addError(&PatternError{Pattern: pattern, Offset: -1, Kind: ErrNilCallback, Msg: "callback is nil"})
 		return
 	}
 
-	mf, err := t.createMatcherFunc(pattern, "")
-	if err != nil {
-		t.setError(err)
+	// Class names are checked when compiling, as the classes may be defined later
+	if _, err := parsePattern(pattern); err != nil {
+		t.addError(err)
+		return
 	}
-	t.matchFuncs = append(t.matchFuncs, mf)
+
+	t.patterns = append(t.patterns, pattern)
+	t.priorities = append(t.priorities, 0)
 	t.callbacks = append(t.callbacks, f)
+	t.compiled = nil
 }
 
-// Class registers a new class with the given matcher function. The function
+// PatternPriority adds a new pattern with the given priority, see Pattern().
+// In longest match mode, the pattern with the highest priority is used when
+// several patterns match the same length. The default priority is 0. The
+// priority has no effect otherwise.
+func (t *Tokenizer) PatternPriority(pattern string, priority int, f func(Token) error) {
+	n := len(t.patterns)
+	t.Pattern(pattern, f)
+
+	if len(t.patterns) > n {
+		t.priorities[n] = priority
+	}
+}
+
+// ClassFunc registers a new class with the given matcher function. The function
 // should return true for any byte that is a legal character in the class.
+// Multi-byte characters are only matched if every byte of them is accepted.
 // The class cannot override any existing names.
 func (t *Tokenizer) ClassFunc(name string, check CheckerFunc) {
-	if _, err := t.getClass(name); err == nil {
-		t.setError(fmt.Errorf("class '%s' already defined", name))
+	if !t.checkClassName(name) {
+		return
+	}
+
+	t.classes = append(t.classes, classDef{name: name, mf: checkFuncToMatchFunc(name, check)})
+	t.compiled = nil
+}
+
+// ClassRuneFunc registers a new class with the given matcher function. The
+// function should return true for any rune that is a legal character in the
+// class. The class cannot override any existing names.
+func (t *Tokenizer) ClassRuneFunc(name string, check RuneCheckerFunc) {
+	if !t.checkClassName(name) {
 		return
 	}
 
-	t.classes[name] = checkFuncToMatchFunc(name, check)
+	t.classes = append(t.classes, classDef{name: name, mf: runeCheckFuncToMatchFunc(name, check)})
+	t.compiled = nil
 }
 
 // ClassOptional creates a new class that matches any or none of the given patterns.
 // The class cannot override any existing names.
 func (t *Tokenizer) ClassOptional(name string, patterns ...string) {
-	patterns = append(patterns, "")
-	t.Class(name, patterns...)
+	n := len(t.classes)
+	t.Class(name, append(slices.Clone(patterns), "")...)
+
+	if len(t.classes) > n {
+		t.classes[n].optional = true
+	}
 }
 
-// Class creates a new class that matches any of the given patterns.
+// Class creates a new class that matches any of the given patterns. The
+// patterns may use classes defined later, and the class itself.
 // The class cannot override any existing names.
 func (t *Tokenizer) Class(name string, patterns ...string) {
-	tokr := New()
-	if ok, _ := tokr.Matches(name, "{var}"); !ok {
-		t.setError(fmt.Errorf("invalid class name '%s'. class names can only contain letters and numbers", name))
-		return
-	}
-
-	if _, err := t.getClass(name); err == nil {
-		t.setError(fmt.Errorf("class '%s' already defined", name))
+	if !t.checkClassName(name) {
 		return
 	}
 
 	if len(patterns) == 0 {
-		t.setError(fmt.Errorf("ClassAny: you must provide at least one pattern"))
+		t.addError(&ClassError{Class: name, Kind: ErrNoPatterns, Msg: "you must provide at least one pattern"})
+		return
 	}
 
-	funcs := []matcherFunc{}
+	valid := []string{}
 
+	// Report errors for all the patterns, not just the first. The class is
+	// still defined with the valid ones, so uses of it are not reported too.
 	for _, pattern := range patterns {
-		mf, err := t.createMatcherFunc(pattern, "")
-		if err != nil {
-			t.setError(err)
-			return
+		if _, err := parsePattern(pattern); err != nil {
+			if perr, ok := err.(*PatternError); ok {
+				perr.Class = name
+			}
+
+			t.addError(err)
+			continue
 		}
-		funcs = append(funcs, mf)
+
+		valid = append(valid, pattern)
 	}
 
-	f := func(iter *stringiter.StringIter) Token {
-		pos := iter.Pos()
+	t.classes = append(t.classes, classDef{name: name, patterns: valid})
+	t.compiled = nil
+}
 
-		for _, mf := range funcs {
-			iter.Push()
-			tok := mf(iter)
-			l := iter.Pop()
+// Strict makes Run return an *UnmatchedError for the first character that
+// no pattern matches, instead of skipping it.
+func (t *Tokenizer) Strict() {
+	t.strict = true
+	t.compiled = nil
+}
 
-			if !tok.matched {
-				continue
-			}
+// Unmatched sets a handler for input that no pattern matches. Consecutive
+// unmatched characters are given to f as a single token. The error returned
+// by f is returned by Run(). Strict() takes priority over the handler.
+func (t *Tokenizer) Unmatched(f func(Token) error) {
+	if f == nil {
+		t.addError(fmt.Errorf("gokenizer: unmatched handler: %w", ErrNilCallback))
+		return
+	}
 
-			// Do not consume if length is 0
-			lexeme := tok.Lexeme
-			if l != 0 {
-				iter.PeekN(uint(l))
-				lexeme = iter.Consume()
-			}
+	t.unmatched = f
+	t.compiled = nil
+}
 
-			return Token{
-				Pos:     pos,
-				Lexeme:  lexeme,
-				Length:  len(lexeme),
-				Source:  iter.Source(),
-				class:   name,
-				values:  tok.values,
-				matched: true,
-			}
-		}
+// LongestMatch makes Run use the pattern with the longest match, instead of
+// the first one that matches. Ties are broken by priority, see
+// PatternPriority(), and then by the order the patterns are defined in.
+// Each pattern still matches the way it otherwise would, so a pattern that
+// could match a longer string by backtracking is not made to.
+func (t *Tokenizer) LongestMatch() {
+	t.longest = true
+	t.compiled = nil
+}
 
-		return Token{matched: false}
-	}
+// LazyLineInfo stops Run from setting Line and Col on tokens, which saves
+// some work for large inputs. Use Token.LineCol() to get them when needed.
+func (t *Tokenizer) LazyLineInfo() {
+	t.lazyLines = true
+	t.compiled = nil
+}
 
-	t.classes[name] = f
+// MaxDepth sets how deep user classes can be nested in a single match,
+// which protects recursive classes from exhausting the stack on deeply
+// nested input. Exceeding it makes Run return an error of kind ErrMaxDepth.
+// A depth below 1 removes the limit. The default is DefaultMaxDepth.
+func (t *Tokenizer) MaxDepth(depth int) {
+	t.maxDepth = max(depth, 0)
+	t.compiled = nil
 }
 
 // Run tokenizer on given input string. Returns first error received by a
 // pattern callback function. Patterns are matched by the order the are
 // defined in.
 func (t *Tokenizer) Run(s string) error {
-	if t.err != nil {
-		return fmt.Errorf("gokenizer: %s", t.err.Error())
+	c, err := t.Compile()
+	if err != nil {
+		return err
 	}
 
-	if len(t.callbacks) == 0 {
-		return nil
-	}
+	return c.Run(s)
+}
 
-	iter := stringiter.New(s)
-	for !iter.Eof() {
-		if err := t.matchNext(&iter); err != nil {
-			return err
-		}
+// RunReader runs the tokenizer on the input read from r. Only as much input
+// as the current match needs is kept in memory, so large inputs can be
+// tokenized in a streaming fashion. Token positions are offsets from the
+// start of the stream, and Token.Source is empty. Returns the first error
+// received by a pattern callback function or the reader.
+func (t *Tokenizer) RunReader(r io.Reader) error {
+	c, err := t.Compile()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return c.RunReader(r)
 }
 
 // Matches returns true if s matches the given pattern. No other patterns
 // in this tokenizer are matched, but the defined classes apply. Error is
-// non-nil if pattern is malformed.
+// non-nil if pattern or the tokenizer is malformed.
 func (t *Tokenizer) Matches(s string, pattern string) (matched bool, err error) {
-	iter := stringiter.New(s)
-
-	mf, err := t.createMatcherFunc(pattern, "")
+	c, err := t.Compile()
 	if err != nil {
 		return matched, err
 	}
 
-	res := mf(&iter)
-	return res.matched && iter.Eof(), err
+	return c.Matches(s, pattern)
 }
 
-// Continue matching until one is found. Returns callbacks error.
-func (t *Tokenizer) matchNext(iter *stringiter.StringIter) error {
-	callbackIdx := 0
-	result := Token{}
-	pos := iter.Pos()
-
-	for idx, mf := range t.matchFuncs {
-		iter.Push()
-		if result = mf(iter); result.matched {
-			callbackIdx = idx
-			break
-		}
+// Compile returns an immutable copy of the tokenizer, which is safe to use
+// from multiple goroutines at once. Changes made to the tokenizer after
+// compiling do not affect the compiled one. Returns an error if any pattern
+// or class is malformed, refers to an unknown class, or is left recursive.
+func (t *Tokenizer) Compile() (*Compiled, error) {
+	if t.compiled != nil {
+		return t.compiled, nil
+	}
 
-		iter.Pop()
+	c, errs := t.compile()
+	if err := errors.Join(append(slices.Clone(t.errs), errs...)...); err != nil {
+		return nil, err
 	}
 
-	if !result.matched {
-		iter.Consume() // Next
-		return nil
+	t.compiled = c
+	return c, nil
+}
+
+// Convert boolean checker function to token matcher function. The matcher
+// is greedy, but gives back one character at a time if the rest of the
+// pattern does not match.
+func checkFuncToMatchFunc(class string, check CheckerFunc) matcherFunc {
+	return greedyMatchFunc(class, byteChecker(check), 1, -1)
+}
+
+// Convert rune checker function to token matcher function. Works the same
+// as checkFuncToMatchFunc.
+func runeCheckFuncToMatchFunc(class string, check RuneCheckerFunc) matcherFunc {
+	return greedyMatchFunc(class, runeChecker(check), 1, -1)
+}
+
+// Checks a single character, given as a rune and its bytes in the input.
+// Invalid UTF-8 is given as utf8.RuneError and the invalid byte.
+type charCheckerFunc func(r rune, raw string) bool
+
+// Converts a rune checker to a character checker.
+func runeChecker(check RuneCheckerFunc) charCheckerFunc {
+	return func(r rune, _ string) bool {
+		return check(r)
 	}
+}
 
-	token := Token{
-		Pos:    pos,
-		Lexeme: result.Lexeme,
-		Source: iter.Source(),
-		Length: len(result.Lexeme),
-		values: result.values,
+// Converts a byte checker to a character checker. Multi-byte characters
+// are only accepted if check returns true for every byte of the input.
+func byteChecker(check CheckerFunc) charCheckerFunc {
+	return func(_ rune, raw string) bool {
+		for i := range len(raw) {
+			if !check(raw[i]) {
+				return false
+			}
+		}
+
+		return true
 	}
+}
 
-	return t.callbacks[callbackIdx](token)
+// Returns a matcher consuming as many runes accepted by check as possible,
+// requiring at least min and at most max of them. max is -1 for no upper
+// limit. Shorter matches are tried in decreasing length when the
+// continuation rejects the longer ones.
+func greedyMatchFunc(class string, check charCheckerFunc, min int, max int) matcherFunc {
+	return func(iter *matchIter, k func(Token) bool) bool {
+		pos := iter.Pos()
+		ends := []int{}
+
+		for len(ends) != max {
+			r, size := iter.PeekRune()
+			if size == 0 || !check(r, iter.Slice(iter.Pos(), iter.Pos()+size)) {
+				break
+			}
+
+			iter.ConsumeRune()
+			ends = append(ends, iter.Pos())
+		}
+
+		for n := len(ends); n >= min; n-- {
+			end := pos
+			if n > 0 {
+				end = ends[n-1]
+			}
+
+			iter.SetPos(end)
+			word := iter.Slice(pos, end)
+
+			tok := Token{
+				Pos:     pos,
+				Lexeme:  word,
+				Source:  iter.Source(),
+				Length:  len(word),
+				Class:   class,
+				matched: true,
+			}
+
+			if k(tok) {
+				return true
+			}
+		}
+
+		iter.SetPos(pos)
+		return false
+	}
 }
 
-// Convert boolean checker function to token matcher function.
-func checkFuncToMatchFunc(class string, check CheckerFunc) matcherFunc {
-	return func(iter *stringiter.StringIter) Token {
+// Converts a function matching at most one token into a matcher function.
+// The returned tokens matched field reports whether the match succeeded.
+func singleMatchFunc(f func(iter *stringiter.StringIter) Token) matcherFunc {
+	return func(iter *matchIter, k func(Token) bool) bool {
 		pos := iter.Pos()
-		word := ""
 
-		for !iter.Eof() && check(iter.Peek()) {
-			word += iter.Consume()
+		if tok := f(iter.StringIter); tok.matched && k(tok) {
+			return true
 		}
 
-		return Token{
-			Pos:     pos,
-			Lexeme:  word,
-			Source:  iter.Source(),
-			Length:  len(word),
-			class:   class,
-			matched: len(word) > 0,
+		iter.SetPos(pos)
+		return false
+	}
+}
+
+// Returns a matcher that matches mf between min and max times. max is -1
+// for no upper limit. As many repetitions as possible are tried first. The
+// token passed on holds every repetition in its repeats field.
+//
+// Repetitions are matched in a loop rather than by nesting continuations,
+// so long inputs do not grow the stack. Backtracking into a repetition
+// matches mf again, skipping the matches already tried.
+func repeatMatchFunc(mf matcherFunc, min, max int) matcherFunc {
+	return func(iter *matchIter, k func(Token) bool) bool {
+		pos := iter.Pos()
+
+		repeats := []Token{}
+		tried := []int{} // Number of matches of mf tried for each repetition
+		skip := 0        // Number of matches to skip for the next repetition
+
+		for {
+			if max == -1 || len(repeats) < max {
+				if tok, n, ok := nthMatch(iter, mf, skip); ok {
+					repeats = append(repeats, tok)
+					tried = append(tried, n)
+					skip = 0
+					continue
+				}
+			}
+
+			if len(repeats) >= min {
+				lexeme := iter.Slice(pos, iter.Pos())
+
+				accepted := k(Token{
+					Pos:     pos,
+					Lexeme:  lexeme,
+					Length:  len(lexeme),
+					Source:  iter.Source(),
+					matched: true,
+					repeats: slices.Clone(repeats),
+				})
+
+				if accepted {
+					return true
+				}
+			}
+
+			if len(repeats) == 0 {
+				iter.SetPos(pos)
+				return false
+			}
+
+			// Try the next match of the last repetition
+			last := len(repeats) - 1
+			iter.SetPos(repeats[last].Pos)
+			skip = tried[last]
+			repeats, tried = repeats[:last], tried[:last]
 		}
 	}
 }
 
+// Matches mf at the current position, skipping its first skip matches and
+// any empty ones. Returns the match, the number of matches tried, and true
+// if there was one. The iterator is left at the end of the match.
+func nthMatch(iter *matchIter, mf matcherFunc, skip int) (match Token, tried int, ok bool) {
+	start := iter.Pos()
+
+	ok = mf(iter, func(tok Token) bool {
+		tried++
+
+		// Empty repetitions would never end
+		if tried <= skip || iter.Pos() == start {
+			return false
+		}
+
+		tok.Pos = start
+		tok.Length = len(tok.Lexeme)
+		tok.Source = iter.Source()
+		match = tok
+		return true
+	})
+
+	return match, tried, ok
+}
+
 // Returns a function that matches the string literal s.
 func literalMatcherFunc(s string) matcherFunc {
-	return func(iter *stringiter.StringIter) Token {
+	return singleMatchFunc(func(iter *stringiter.StringIter) Token {
 		pos := iter.Pos()
 		iter.PeekN(uint(len(s)))
 		lexeme := iter.Consume()
@@ -234,135 +467,152 @@ func literalMatcherFunc(s string) matcherFunc {
 			Pos:     pos,
 			Length:  len(lexeme),
 			Source:  iter.Source(),
-			class:   "",
 			matched: lexeme == s,
 		}
-	}
+	})
 }
 
-func parseClass(iter *stringiter.StringIter) (name string, err error) {
-	if iter.Consume() != "{" {
-		return name, fmt.Errorf("expected { before class name")
-	}
+// Returns a matcher that matches any of the given matchers, tried in order.
+// The token passed on is of the given class.
+func classMatchFunc(class string, funcs []matcherFunc) matcherFunc {
+	return func(iter *matchIter, k func(Token) bool) bool {
+		pos := iter.Pos()
 
-	if !iter.Seek('}') {
-		return name, fmt.Errorf("expected } after class name")
-	}
+		// The patterns are tried in order, so a later pattern is only used
+		// if no match of the previous ones lets the rest of the pattern match.
+		for _, mf := range funcs {
+			matched := mf(iter, func(tok Token) bool {
+				return k(Token{
+					Pos:      pos,
+					Lexeme:   tok.Lexeme,
+					Length:   len(tok.Lexeme),
+					Source:   iter.Source(),
+					Class:    class,
+					Children: tok.Children,
+					matched:  true,
+				})
+			})
+
+			if matched {
+				return true
+			}
+		}
 
-	name = iter.Consume()
-	iter.Consume() // }
-	return name, err
+		return false
+	}
 }
 
-// Returns two equal length lists of matcher functions and their class names.
-func (t *Tokenizer) parsePattern(pattern string) (funcs []matcherFunc, classNames []string, err error) {
-	pIter := stringiter.New(pattern)
-
-	for !pIter.Eof() {
-		if pIter.Peek() == '{' {
-			// Parse class name if we find a {
-			pIter.Restore()
-			className, err := parseClass(&pIter)
-			if err != nil {
-				return funcs, classNames, err
-			}
-
-			f, err := t.getClass(className)
-			if err != nil {
-				return funcs, classNames, err
-			}
+// Returns a matcher that matches each of funcs in sequence. The elements
+// are the parsed pattern elements of each function, and give the class and
+// capture name of the values.
+func sequenceMatchFunc(funcs []matcherFunc, elems []patternElem) matcherFunc {
+	return func(iter *matchIter, k func(Token) bool) bool {
+		pos := iter.Pos()
 
-			funcs = append(funcs, f)
-			classNames = append(classNames, className)
-		} else if pIter.Seek('{') {
-			// Parse static word if there are characters before a {
-			staticWord := pIter.Consume()
-			if staticWord == "" {
-				return funcs, classNames, fmt.Errorf("parser error")
+		// Matches the elements from idx and onwards. When an element fails,
+		// the previous one is asked for its next possible match.
+		var next func(idx int, children []Token) bool
+		next = func(idx int, children []Token) bool {
+			if idx == len(funcs) {
+				matchedString := iter.Slice(pos, iter.Pos())
+
+				return k(Token{
+					Lexeme:   matchedString,
+					Length:   len(matchedString),
+					Pos:      pos,
+					Source:   iter.Source(),
+					Children: slices.Clone(children),
+					matched:  true,
+				})
 			}
 
-			funcs = append(funcs, literalMatcherFunc(staticWord))
-			classNames = append(classNames, "")
-		} else {
-			// Otherwise the rest of the pattern string is a static word
-			funcs = append(funcs, literalMatcherFunc(pIter.Remainder()))
-			classNames = append(classNames, "")
-			break
+			start := iter.Pos()
+
+			return funcs[idx](iter, func(tok Token) bool {
+				tok.Pos = start
+				tok.Length = len(tok.Lexeme)
+				tok.Source = iter.Source()
+
+				ref := elems[idx].ref
+				if ref.name == "" {
+					return next(idx+1, children)
+				}
+
+				if tok.repeats != nil {
+					reps := children
+					for _, rep := range tok.repeats {
+						rep.Class, rep.Capture = ref.name, ref.capture
+						reps = append(reps, rep)
+					}
+					return next(idx+1, reps)
+				}
+
+				tok.Class, tok.Capture = ref.name, ref.capture
+				return next(idx+1, append(children, tok))
+			})
 		}
-	}
 
-	return funcs, classNames, err
-}
-
-// Returns class matchFunc from either global or local context
-func (t *Tokenizer) getClass(name string) (mf matcherFunc, err error) {
-	mf, ok := classes[name]
-	if !ok {
-		mf, ok = t.classes[name]
-		if !ok {
-			return mf, fmt.Errorf("unknown class '%s'", name)
-		}
+		return next(0, nil)
 	}
+}
 
-	return mf, err
+// Returns true if mf matches the empty string.
+func matchesEmpty(mf matcherFunc) bool {
+	s := stringiter.New("")
+	iter := matchIter{StringIter: &s}
+	return mf(&iter, func(Token) bool {
+		return true
+	})
 }
 
-// Returns a function that matches based on the given pattern.
-func (t *Tokenizer) createMatcherFunc(pattern string, class string) (mf matcherFunc, err error) {
-	if pattern == "" {
-		return func(iter *stringiter.StringIter) Token {
-			return Token{
-				Pos:     iter.Pos(),
-				Source:  iter.Source(),
-				matched: true,
-			}
-		}, err
+// Returns true if a built-in or user class has the given name.
+// Returns true if name can be used for a new class. Otherwise the error is
+// reported and false is returned.
+func (t *Tokenizer) checkClassName(name string) bool {
+	if !validName(name) {
+		t.addError(&ClassError{Class: name, Kind: ErrInvalidName, Msg: "class names can only contain letters, $ and _"})
+		return false
 	}
 
-	funcs, classNames, err := t.parsePattern(pattern)
-	if err != nil {
-		return mf, err
+	if t.hasClass(name) {
+		t.addError(&ClassError{Class: name, Kind: ErrClassDefined, Msg: "class already defined"})
+		return false
 	}
 
-	f := func(iter *stringiter.StringIter) (res Token) {
-		pos := iter.Pos()
-		values := make(map[string][]Token)
+	return true
+}
 
-		for idx, mf := range funcs {
-			pos := iter.Pos()
-			tempResult := mf(iter)
-			if !tempResult.matched {
-				return res
-			}
+func (t *Tokenizer) hasClass(name string) bool {
+	if _, ok := classes[name]; ok {
+		return true
+	}
 
-			tempResult.Pos = pos
-			tempResult.Length = len(tempResult.Lexeme)
-			tempResult.Source = iter.Source()
+	if _, ok := stringClasses[name]; ok {
+		return true
+	}
 
-			if className := classNames[idx]; className != "" {
-				values[className] = append(values[className], tempResult)
-			}
-		}
+	return slices.ContainsFunc(t.classes, func(def classDef) bool {
+		return def.name == name
+	})
+}
 
-		matchedString := iter.Source()[pos:iter.Pos()]
+// Returns true if name is a legal class or capture name.
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
 
-		return Token{
-			Lexeme:  matchedString,
-			Length:  len(matchedString),
-			Pos:     pos,
-			Source:  iter.Source(),
-			matched: true,
-			class:   class,
-			values:  values,
+	for _, r := range name {
+		if !isLetter(r) && r != '$' && r != '_' {
+			return false
 		}
 	}
 
-	return f, err
+	return true
 }
 
-// Sets error if not nil
-func (t *Tokenizer) setError(err error) {
-	if t.err == nil {
-		t.err = err
-	}
+// Adds a configuration error, which is returned by Run() and Compile().
+func (t *Tokenizer) addError(err error) {
+	t.errs = append(t.errs, err)
+	t.compiled = nil
 }
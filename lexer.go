@@ -0,0 +1,196 @@
+package gokenizer
+
+import (
+	"io"
+	"iter"
+
+	"github.com/jesperkha/gokenizer/stringiter"
+)
+
+// Lexer gives the tokens of an input one at a time. It is created with
+// Tokenizer.Lex() or Compiled.Lex(). The pattern callbacks are called as
+// tokens are returned by Next(), and any error they return is returned by
+// Next().
+type Lexer struct {
+	tokr      *Compiled
+	iter      stringiter.StringIter
+	unmatched int       // Start of skipped input not yet given to the unmatched handler
+	queue     []lexItem // Matched tokens not yet returned
+	err       error     // Returned by every call once set, io.EOF at end of input
+}
+
+// A matched token and the callback to call when it is returned.
+type lexItem struct {
+	token    Token
+	callback func(Token) error
+	pattern  int // Index of the pattern that matched, -1 for unmatched input
+}
+
+// Lex returns a lexer for the input string s.
+func (t *Tokenizer) Lex(s string) *Lexer {
+	return t.newLexer(stringiter.New(s))
+}
+
+// LexReader returns a lexer for the input read from r. See RunReader().
+func (t *Tokenizer) LexReader(r io.Reader) *Lexer {
+	return t.newLexer(stringiter.NewReader(r))
+}
+
+// Returns a lexer for the compiled t, or one returning the tokenizers error.
+func (t *Tokenizer) newLexer(iter stringiter.StringIter) *Lexer {
+	c, err := t.Compile()
+	if err != nil {
+		return &Lexer{err: err}
+	}
+
+	return c.newLexer(iter)
+}
+
+// Lex returns a lexer for the input string s.
+func (c *Compiled) Lex(s string) *Lexer {
+	return c.newLexer(stringiter.New(s))
+}
+
+// LexReader returns a lexer for the input read from r. See
+// Tokenizer.RunReader().
+func (c *Compiled) LexReader(r io.Reader) *Lexer {
+	return c.newLexer(stringiter.NewReader(r))
+}
+
+func (c *Compiled) newLexer(iter stringiter.StringIter) *Lexer {
+	return &Lexer{
+		tokr:      c,
+		iter:      iter,
+		unmatched: -1,
+	}
+}
+
+// Next returns the next token and calls the callback of the pattern that
+// matched it. Callback errors are wrapped in a *CallbackError. Returns
+// io.EOF when there are no more tokens. Once an error is returned, the same
+// error is returned by every following call.
+func (l *Lexer) Next() (Token, error) {
+	tok, _, err := l.next()
+	return tok, err
+}
+
+// Works like Next, but also returns the index of the pattern that matched
+// the token, or -1 for unmatched input.
+func (l *Lexer) next() (Token, int, error) {
+	if len(l.queue) == 0 && l.err == nil {
+		l.fill()
+	}
+
+	if len(l.queue) == 0 {
+		return Token{}, -1, l.err
+	}
+
+	item := l.queue[0]
+	l.queue = l.queue[1:]
+
+	if err := item.callback(item.token); err != nil {
+		l.err = &CallbackError{Token: item.token, Pattern: item.token.Pattern, Err: err}
+		l.queue = nil
+		return item.token, item.pattern, l.err
+	}
+
+	return item.token, item.pattern, nil
+}
+
+// Peek returns the next token without consuming it. No callback is called.
+// Returns io.EOF when there are no more tokens.
+func (l *Lexer) Peek() (Token, error) {
+	if len(l.queue) == 0 && l.err == nil {
+		l.fill()
+	}
+
+	if len(l.queue) == 0 {
+		return Token{}, l.err
+	}
+
+	return l.queue[0].token, nil
+}
+
+// All returns an iterator over the remaining tokens. Iteration stops after
+// the first error, which is yielded with an empty token. io.EOF is not
+// yielded.
+func (l *Lexer) All() iter.Seq2[Token, error] {
+	return func(yield func(Token, error) bool) {
+		for {
+			tok, err := l.Next()
+			if err == io.EOF {
+				return
+			}
+
+			if err != nil {
+				yield(Token{}, err)
+				return
+			}
+
+			if !yield(tok, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Matches input until at least one token is queued, or sets the error if
+// there are none left.
+func (l *Lexer) fill() {
+	c := l.tokr
+
+	for !l.iter.Eof() {
+		pos := l.iter.Pos()
+
+		// Input before pos is no longer needed unless it is unmatched
+		if l.unmatched == -1 {
+			l.iter.Discard(pos)
+		}
+
+		token, callbackIdx, err := c.matchNext(&l.iter)
+		if err != nil {
+			l.err = err
+			return
+		}
+
+		if callbackIdx == -1 {
+			l.iter.ConsumeRune() // Next
+
+			if c.strict {
+				l.err = &UnmatchedError{Token: c.newToken(&l.iter, pos, l.iter.Pos(), nil)}
+				return
+			}
+
+			// Unmatched input is only kept if there is a handler for it
+			if l.unmatched == -1 && c.unmatched != nil {
+				l.unmatched = pos
+			}
+			continue
+		}
+
+		l.flushUnmatched(pos)
+
+		token.Pattern = c.patterns[callbackIdx]
+		l.queue = append(l.queue, lexItem{token, c.callbacks[callbackIdx], callbackIdx})
+		return
+	}
+
+	l.flushUnmatched(l.iter.Pos())
+
+	if len(l.queue) == 0 {
+		l.err = io.EOF
+		if err := l.iter.Err(); err != nil {
+			l.err = err
+		}
+	}
+}
+
+// Queues the skipped input up to end for the unmatched handler, if any.
+func (l *Lexer) flushUnmatched(end int) {
+	if l.unmatched != -1 && l.tokr.unmatched != nil {
+		token := l.tokr.newToken(&l.iter, l.unmatched, end, nil)
+		l.queue = append(l.queue, lexItem{token, l.tokr.unmatched, -1})
+	}
+
+	l.unmatched = -1
+}
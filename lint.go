@@ -0,0 +1,341 @@
+package gokenizer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jesperkha/gokenizer/stringiter"
+)
+
+// Kinds of problems reported by Lint().
+type WarningKind int
+
+const (
+	WarnPossiblyUnreachable WarningKind = iota // Pattern or class alternative is likely never used
+	WarnEmptyAlternative                       // Class alternative matches the empty string
+	WarnUnusedClass                            // Class is not used by any pattern
+)
+
+// Warning describes a likely mistake in a tokenizer, found by Lint().
+type Warning struct {
+	Pattern string // The pattern, empty for unused classes
+	Class   string // The class the pattern belongs to, empty for Pattern()
+	Kind    WarningKind
+	Msg     string // Description of the problem
+}
+
+func (w Warning) String() string {
+	s := "gokenizer: "
+	if w.Class != "" {
+		s += fmt.Sprintf("class '%s': ", w.Class)
+	}
+
+	if w.Kind != WarnUnusedClass {
+		s += fmt.Sprintf("pattern '%s': ", w.Pattern)
+	}
+
+	return s + w.Msg
+}
+
+// Sample inputs tried against classes to find the strings they match.
+var lintAlphabet = []string{
+	"a", "Z", "æ", "0", "1", ".", "_", "$", "-", "!", "#", "+", "=", "/", ";",
+	"§", "€", " ", "\t", "\n", "a\n", "\"a\"", "{", "}", "(a)", "[a]", "{a}",
+}
+
+// Maximum number of sample strings made for a pattern or element, and how
+// deep recursive classes are followed when making them.
+const (
+	maxLintSamples = 32
+	maxLintDepth   = 3
+)
+
+// Lint looks for likely mistakes in the tokenizer:
+//
+//   - Patterns that are possibly never used, as other patterns match the
+//     same input first. For example "foo" after "{text}".
+//   - Class alternatives that are possibly never used, as earlier
+//     alternatives match the same strings. For example "{number}" after
+//     "{text}".
+//   - Class alternatives, other than the empty one added by ClassOptional(),
+//     that match the empty string.
+//   - Classes that are not used by any pattern.
+//
+// Unused patterns and alternatives are found by trying sample inputs made
+// from them, so not every case is found, and a warning may be wrong when
+// only input outside the samples reaches the pattern. For example "{word}"
+// after "{[a-zA-Zæ]+}" is reported, although "ø" is only matched by
+// "{word}". Returns an error if the tokenizer does not compile.
+func (t *Tokenizer) Lint() (warnings []Warning, err error) {
+	c, err := t.Compile()
+	if err != nil {
+		return warnings, err
+	}
+
+	l := linter{c: c, grammar: make(map[string][][]patternElem)}
+	alternatives := make(map[string][]matcherFunc)
+
+	for _, def := range t.classes {
+		for _, pattern := range def.patterns {
+			mf, elems, _ := c.createMatcherFunc(pattern)
+			alternatives[def.name] = append(alternatives[def.name], mf)
+			l.grammar[def.name] = append(l.grammar[def.name], elems)
+		}
+	}
+
+	patternElems := [][]patternElem{}
+	for i, pattern := range c.patterns {
+		_, elems, _ := c.createMatcherFunc(pattern)
+		patternElems = append(patternElems, elems)
+
+		if other, ok := l.unreachablePattern(i, elems); ok {
+			warnings = append(warnings, Warning{
+				Pattern: pattern,
+				Kind:    WarnPossiblyUnreachable,
+				Msg:     fmt.Sprintf("possibly never used, as '%s' matches all samples first", other),
+			})
+		}
+	}
+
+	nullable := nullableClasses(l.grammar)
+
+	for _, def := range t.classes {
+		for i, pattern := range def.patterns {
+			// The empty alternative added by ClassOptional() is always last
+			if def.optional && i == len(def.patterns)-1 {
+				continue
+			}
+
+			elems := l.grammar[def.name][i]
+
+			if pattern != "" && elemsNullable(elems, nullable) {
+				warnings = append(warnings, Warning{
+					Pattern: pattern,
+					Class:   def.name,
+					Kind:    WarnEmptyAlternative,
+					Msg:     "alternative matches the empty string",
+				})
+			}
+
+			if other, ok := l.unreachableAlternative(alternatives[def.name][:i+1], def.patterns, elems); ok {
+				warnings = append(warnings, Warning{
+					Pattern: pattern,
+					Class:   def.name,
+					Kind:    WarnPossiblyUnreachable,
+					Msg:     fmt.Sprintf("possibly never used, as alternative '%s' matches all samples", other),
+				})
+			}
+		}
+	}
+
+	used := l.usedClasses(patternElems)
+	for _, def := range t.classes {
+		if !used[def.name] {
+			warnings = append(warnings, Warning{
+				Class: def.name,
+				Kind:  WarnUnusedClass,
+				Msg:   "class is not used by any pattern",
+			})
+		}
+	}
+
+	return warnings, err
+}
+
+type linter struct {
+	c       *Compiled
+	grammar map[string][][]patternElem
+}
+
+// Returns an earlier pattern that is used instead of pattern idx for every
+// sample input, and true if there is one.
+func (l *linter) unreachablePattern(idx int, elems []patternElem) (other string, ok bool) {
+	for _, s := range l.elemsSamples(elems, 0) {
+		// Only samples the pattern matches itself are relevant
+		if !l.matches(l.c.matchFuncs[idx], s, false) {
+			continue
+		}
+
+		src := stringiter.New(s)
+		_, winner, err := l.c.matchNext(&src)
+		if err != nil || winner == idx {
+			return "", false
+		}
+
+		if other == "" {
+			other = l.c.patterns[winner]
+		}
+	}
+
+	return other, other != ""
+}
+
+// Returns an earlier alternative that matches every sample string of the
+// last of alts, and true if there is one.
+func (l *linter) unreachableAlternative(alts []matcherFunc, patterns []string, elems []patternElem) (other string, ok bool) {
+	last := len(alts) - 1
+	matched := false
+
+	for _, s := range l.elemsSamples(elems, 0) {
+		if !l.matches(alts[last], s, true) {
+			continue
+		}
+
+		covered := false
+		for i, mf := range alts[:last] {
+			if l.matches(mf, s, true) {
+				if !matched {
+					other = patterns[i]
+				}
+				covered = true
+				break
+			}
+		}
+
+		if !covered {
+			return "", false
+		}
+
+		matched = true
+	}
+
+	return other, matched
+}
+
+// Returns true if mf matches all of s, or a non-empty prefix of it if full
+// is false.
+func (l *linter) matches(mf matcherFunc, s string, full bool) bool {
+	src := stringiter.New(s)
+	iter := l.c.newMatchIter(&src)
+
+	return mf(&iter, func(Token) bool {
+		if full {
+			return iter.Eof()
+		}
+		return iter.Pos() > 0
+	})
+}
+
+// Returns sample strings matched by the elements in sequence.
+func (l *linter) elemsSamples(elems []patternElem, depth int) []string {
+	samples := []string{""}
+
+	for _, e := range elems {
+		next := []string{}
+		for _, prefix := range samples {
+			for _, s := range l.elemSamples(e, depth) {
+				if len(next) < maxLintSamples {
+					next = append(next, prefix+s)
+				}
+			}
+		}
+
+		samples = next
+	}
+
+	return samples
+}
+
+// Returns sample strings matched by a single element.
+func (l *linter) elemSamples(e patternElem, depth int) []string {
+	if e.ref.name == "" {
+		return []string{e.literal}
+	}
+
+	samples := []string{}
+	if e.ref.min == 0 {
+		samples = append(samples, "")
+	}
+
+	for _, s := range l.refSamples(e.ref, depth) {
+		if len(samples) < maxLintSamples {
+			samples = append(samples, strings.Repeat(s, max(e.ref.min, 1)))
+		}
+	}
+
+	return samples
+}
+
+// Returns sample strings matched by one repetition of the reference.
+func (l *linter) refSamples(ref classRef, depth int) (samples []string) {
+	if depth > maxLintDepth {
+		return samples
+	}
+
+	if ref.group != nil {
+		for _, branch := range ref.group {
+			samples = append(samples, l.elemsSamples(branch, depth)...)
+		}
+		return samples
+	}
+
+	if alts, ok := l.grammar[ref.name]; ok {
+		for _, elems := range alts {
+			samples = append(samples, l.elemsSamples(elems, depth+1)...)
+		}
+		return samples
+	}
+
+	if ref.set != nil {
+		for _, s := range lintAlphabet {
+			if r := []rune(s); len(r) == 1 && ref.set(r[0]) {
+				samples = append(samples, s)
+			}
+		}
+		return samples
+	}
+
+	// The content of until is not checked by the class
+	if ref.name == "until" {
+		return []string{"a"}
+	}
+
+	mf, err := l.c.getClass(ref.name, ref.args)
+	if err != nil {
+		return samples
+	}
+
+	// Built-in classes with a length match a repeated character
+	n := 1
+	if ref.args != nil && ref.args.isLength {
+		n = max(ref.args.min, 1)
+	}
+
+	for _, s := range lintAlphabet {
+		if s = strings.Repeat(s, n); l.matches(mf, s, true) {
+			samples = append(samples, s)
+		}
+	}
+
+	return samples
+}
+
+// Returns the set of classes used by the patterns, directly or through
+// other classes.
+func (l *linter) usedClasses(patterns [][]patternElem) map[string]bool {
+	used := make(map[string]bool)
+
+	var use func(elems []patternElem)
+	use = func(elems []patternElem) {
+		for _, e := range elems {
+			for _, branch := range e.ref.group {
+				use(branch)
+			}
+
+			if e.ref.name == "" || used[e.ref.name] {
+				continue
+			}
+
+			used[e.ref.name] = true
+			for _, alt := range l.grammar[e.ref.name] {
+				use(alt)
+			}
+		}
+	}
+
+	for _, elems := range patterns {
+		use(elems)
+	}
+
+	return used
+}
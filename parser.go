@@ -0,0 +1,393 @@
+package gokenizer
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jesperkha/gokenizer/stringiter"
+)
+
+// A class reference parsed from a pattern. Inline groups and character
+// sets are references named by their source text, such as "(GET|POST)".
+type classRef struct {
+	name    string
+	group   [][]patternElem // Branches of an inline group, nil for classes
+	set     RuneCheckerFunc // Checker of a character set, nil for classes
+	args    *classArgs      // Arguments of a parameterized class, nil if not given
+	capture string          // Capture name, empty if not given
+	min     int             // Minimum number of repetitions
+	max     int             // Maximum number of repetitions, -1 if unbounded
+	offset  int             // Byte offset of the reference in the pattern
+}
+
+// The argument of a parameterized class, either a string as in {until ";"}
+// or a length as in {number 2,4}.
+type classArgs struct {
+	str      string
+	min      int
+	max      int // -1 if unbounded
+	isLength bool
+}
+
+// An element of a parsed pattern. Either a static word or a class reference.
+type patternElem struct {
+	literal string
+	ref     classRef // Empty name for static words
+}
+
+// Returns true if the element can match the empty string, given the set of
+// classes that can.
+func (e patternElem) nullable(nullable map[string]bool) bool {
+	if e.ref.name == "" {
+		return false
+	}
+
+	if e.ref.min == 0 {
+		return true
+	}
+
+	if e.ref.args != nil {
+		return e.ref.args.isLength && e.ref.args.min == 0
+	}
+
+	if e.ref.group != nil {
+		return slices.ContainsFunc(e.ref.group, func(elems []patternElem) bool {
+			return elemsNullable(elems, nullable)
+		})
+	}
+
+	return nullable[e.ref.name]
+}
+
+// Returns the names of the classes the elements can start by matching,
+// including those at the start of inline groups.
+func firstClasses(elems []patternElem, nullable map[string]bool) (names []string) {
+	for _, e := range elems {
+		if e.ref.group != nil {
+			for _, branch := range e.ref.group {
+				names = append(names, firstClasses(branch, nullable)...)
+			}
+		} else if e.ref.name != "" && e.ref.set == nil {
+			names = append(names, e.ref.name)
+		}
+
+		if !e.nullable(nullable) {
+			break
+		}
+	}
+
+	return names
+}
+
+// Parses a class reference on the form {name} or {name:capture}. It may
+// end with a quantifier: {name*}, {name+}, {name?}, {name{n}}, {name{n,}}
+// or {name{n,m}}. The name may be an inline group or a character set, see
+// parseGroup and parseSet, or be followed by an argument, see parseArgs.
+// Errors are of type *PatternError.
+func parseClass(iter *stringiter.StringIter) (ref classRef, err error) {
+	ref.offset = iter.Pos()
+	if iter.Consume() != "{" {
+		return ref, patternError(ErrSyntax, iter.Pos(), "expected { before class name")
+	}
+
+	if iter.Peek() == '(' {
+		if ref.group, err = parseGroup(iter); err != nil {
+			return ref, err
+		}
+
+		ref.name = iter.Slice(ref.offset+1, iter.Pos())
+	} else if iter.Peek() == '[' {
+		if ref.set, err = parseSet(iter); err != nil {
+			return ref, err
+		}
+
+		ref.name = iter.Slice(ref.offset+1, iter.Pos())
+	}
+
+	for ref.group == nil && ref.set == nil && !iter.Eof() && !strings.ContainsRune(":*+?{} ", rune(iter.Peek())) {
+		ref.name += iter.Consume()
+	}
+
+	if ref.name == "" {
+		return ref, patternError(ErrSyntax, ref.offset+1, "expected class name")
+	}
+
+	if iter.Peek() == ' ' {
+		if ref.args, err = parseArgs(iter); err != nil {
+			return ref, err
+		}
+	}
+
+	if iter.Peek() == ':' {
+		iter.Consume()
+		start := iter.Pos()
+		for !iter.Eof() && !strings.ContainsRune("*+?{}", rune(iter.Peek())) {
+			ref.capture += iter.Consume()
+		}
+
+		if !validName(ref.capture) {
+			return ref, patternError(ErrInvalidName, start, "invalid capture name '%s'", ref.capture)
+		}
+	}
+
+	ref.min, ref.max = 1, 1
+
+	switch iter.Peek() {
+	case '*':
+		ref.min, ref.max = 0, -1
+		iter.Consume()
+	case '+':
+		ref.min, ref.max = 1, -1
+		iter.Consume()
+	case '?':
+		ref.min, ref.max = 0, 1
+		iter.Consume()
+	case '{':
+		iter.Consume()
+		start := iter.Pos()
+		if !iter.Seek('}') {
+			return ref, patternError(ErrSyntax, start, "expected } after repetition count")
+		}
+
+		if ref.min, ref.max, err = parseRepetition(iter.Consume()); err != nil {
+			return ref, patternError(ErrSyntax, start, "%s", err.Error())
+		}
+
+		iter.Consume() // }
+	}
+
+	if pos := iter.Pos(); iter.Consume() != "}" {
+		return ref, patternError(ErrSyntax, pos, "expected } after class name")
+	}
+
+	return ref, err
+}
+
+// Parses the argument of a parameterized class after its name: a quoted
+// string, or a length on the form n, n, or n,m. Errors are of type
+// *PatternError.
+func parseArgs(iter *stringiter.StringIter) (args *classArgs, err error) {
+	for iter.Peek() == ' ' {
+		iter.Consume()
+	}
+
+	start := iter.Pos()
+	args = &classArgs{}
+
+	if iter.Peek() == '"' {
+		quoted, err := strconv.QuotedPrefix(iter.Remainder())
+		if err != nil {
+			return args, patternError(ErrSyntax, start, "invalid string argument")
+		}
+
+		iter.SetPos(start + len(quoted))
+		args.str, err = strconv.Unquote(quoted)
+		return args, err
+	}
+
+	length := ""
+	for !iter.Eof() && strings.ContainsRune("0123456789,", rune(iter.Peek())) {
+		length += iter.Consume()
+	}
+
+	if length == "" {
+		return args, patternError(ErrSyntax, start, "expected string or length argument")
+	}
+
+	args.isLength = true
+	if args.min, args.max, err = parseRepetition(length); err != nil {
+		return args, patternError(ErrSyntax, start, "%s", err.Error())
+	}
+
+	return args, err
+}
+
+// Parses a repetition count on the form n, n, or n,m.
+func parseRepetition(s string) (min int, max int, err error) {
+	minStr, maxStr, hasComma := strings.Cut(s, ",")
+
+	if min, err = strconv.Atoi(minStr); err != nil || min < 0 {
+		return min, max, fmt.Errorf("invalid repetition count '%s'", s)
+	}
+
+	if !hasComma {
+		max = min
+	} else if maxStr == "" {
+		max = -1
+	} else if max, err = strconv.Atoi(maxStr); err != nil || max < min {
+		return min, max, fmt.Errorf("invalid repetition count '%s'", s)
+	}
+
+	if max == 0 {
+		return min, max, fmt.Errorf("invalid repetition count '%s'", s)
+	}
+
+	return min, max, nil
+}
+
+// Parses an inline group on the form (a|b|c), where each branch is a
+// pattern. A literal (, ), | or \ in a branch is escaped with \. Errors are
+// of type *PatternError.
+func parseGroup(iter *stringiter.StringIter) (group [][]patternElem, err error) {
+	start := iter.Pos()
+	iter.Consume() // (
+
+	for {
+		branch, err := parseElems(iter, true)
+		if err != nil {
+			return group, err
+		}
+
+		group = append(group, branch)
+
+		switch iter.Consume() {
+		case "|":
+			continue
+		case ")":
+			return group, nil
+		default:
+			return group, patternError(ErrSyntax, start, "expected ) after group")
+		}
+	}
+}
+
+// Parses a character set on the form [abc], which matches a single
+// character. Ranges are written as a-z, and a leading ^ negates the set. A
+// literal ], ^, - or \ is escaped with \. Errors are of type *PatternError.
+func parseSet(iter *stringiter.StringIter) (check RuneCheckerFunc, err error) {
+	start := iter.Pos()
+	iter.Consume() // [
+
+	negate := iter.Peek() == '^'
+	if negate {
+		iter.Consume()
+	}
+
+	// Inclusive ranges of runes in the set
+	ranges := [][2]rune{}
+
+	// Returns the next character of the set, and false at the end of it
+	next := func() (r rune, ok bool) {
+		switch iter.Peek() {
+		case ']':
+			return r, false
+		case '\\':
+			iter.Consume()
+		}
+
+		r, _ = iter.PeekRune()
+		return r, iter.ConsumeRune() != ""
+	}
+
+	for {
+		lo, ok := next()
+		if !ok {
+			break
+		}
+
+		hi := lo
+		if iter.Peek() == '-' && iter.Slice(iter.Pos()+1, iter.Pos()+2) != "]" {
+			iter.Consume()
+			if hi, ok = next(); !ok || hi < lo {
+				return check, patternError(ErrSyntax, start, "invalid range in character set")
+			}
+		}
+
+		ranges = append(ranges, [2]rune{lo, hi})
+	}
+
+	if iter.Consume() != "]" {
+		return check, patternError(ErrSyntax, start, "expected ] after character set")
+	}
+
+	if len(ranges) == 0 {
+		return check, patternError(ErrSyntax, start, "empty character set")
+	}
+
+	return func(r rune) bool {
+		for _, rng := range ranges {
+			if r >= rng[0] && r <= rng[1] {
+				return !negate
+			}
+		}
+
+		return negate
+	}, err
+}
+
+// Parses the pattern into a list of static words and class references.
+// Class names are not resolved, so the classes may be defined later.
+// Errors are of type *PatternError.
+func parsePattern(pattern string) (elems []patternElem, err error) {
+	pIter := stringiter.New(pattern)
+
+	elems, err = parseElems(&pIter, false)
+	if err != nil {
+		if perr, ok := err.(*PatternError); ok {
+			perr.Pattern = pattern
+		}
+	}
+
+	return elems, err
+}
+
+// Parses static words and class references until the end of input, or the
+// end of a group branch if inGroup is true. A literal { or } is written as
+// {{ or }}. Errors are of type *PatternError.
+func parseElems(iter *stringiter.StringIter, inGroup bool) (elems []patternElem, err error) {
+	staticWord := ""
+
+	// Adds the static word read so far, if any
+	addWord := func() {
+		if staticWord != "" {
+			elems = append(elems, patternElem{literal: staticWord})
+			staticWord = ""
+		}
+	}
+
+	for !iter.Eof() {
+		pos := iter.Pos()
+		c := iter.Peek()
+
+		switch {
+		case inGroup && c == '\\':
+			// Escaped character in a group
+			iter.Consume()
+			if iter.Eof() {
+				return elems, patternError(ErrSyntax, pos, "expected character after \\")
+			}
+			staticWord += iter.ConsumeRune()
+
+		case (c == '{' || c == '}') && iter.Slice(pos+1, pos+2) == string(c):
+			// Escaped brace
+			staticWord += string(c)
+			iter.SetPos(pos + 2)
+
+		case inGroup && (c == '|' || c == ')' || c == '}'):
+			// End of branch, an unbalanced } is reported by parseGroup
+			addWord()
+			return elems, err
+
+		case c == '{':
+			// Parse class name if we find a {
+			addWord()
+			ref, err := parseClass(iter)
+			if err != nil {
+				return elems, err
+			}
+
+			elems = append(elems, patternElem{ref: ref})
+
+		case c == '}':
+			return elems, patternError(ErrSyntax, pos, "unbalanced }, use }} to match a }")
+
+		default:
+			staticWord += iter.Consume()
+		}
+	}
+
+	addWord()
+	return elems, err
+}
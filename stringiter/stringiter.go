@@ -1,36 +1,162 @@
 package stringiter
 
 import (
+	"io"
+	"slices"
 	"strings"
+	"unicode/utf8"
 )
 
+// Minimum number of bytes to read from a reader at a time
+const minRead = 4096
+
 type StringIter struct {
-	s        string
+	s        string // Buffered input, starting at offset base
+	base     int
 	pos      int
 	peekPos  int
 	posStack []int
+
+	stream bool      // True if reading from a reader
+	reader io.Reader // Nil once the reader is exhausted
+	err    error     // First error returned by the reader, other than io.EOF
+
+	// Byte offsets of the start of each line after base, built lazily up
+	// to indexed. baseLine and baseCol are the line and column of base.
+	lineStarts []int
+	indexed    int
+	baseLine   int
+	baseCol    int
+
+	// The last position given to LineColAt, and its line and column. Columns
+	// are counted from it when on the same line, so finding the position of
+	// each token on a long line is not quadratic.
+	lastPos  int
+	lastLine int
+	lastCol  int
 }
 
 func New(s string) StringIter {
 	return StringIter{
-		s:       s,
-		pos:     0,
-		peekPos: 0,
+		s:        s,
+		pos:      0,
+		peekPos:  0,
+		baseLine: 1,
+		baseCol:  1,
+	}
+}
+
+// Returns an iterator reading from r only as far as it is used. All
+// positions are byte offsets from the start of the stream. Use Discard to
+// free input that is no longer needed.
+func NewReader(r io.Reader) StringIter {
+	return StringIter{
+		stream:   true,
+		reader:   r,
+		baseLine: 1,
+		baseCol:  1,
+	}
+}
+
+// Returns offset of the end of the buffered input.
+func (iter *StringIter) end() int {
+	return iter.base + len(iter.s)
+}
+
+// Reads more input until the buffer reaches offset end. Returns false if
+// the input ends before that.
+func (iter *StringIter) fill(end int) bool {
+	for iter.end() < end {
+		if iter.reader == nil {
+			return false
+		}
+
+		buf := make([]byte, max(minRead, len(iter.s)))
+		n, err := iter.reader.Read(buf)
+		iter.s += string(buf[:n])
+
+		if err != nil {
+			if err != io.EOF {
+				iter.err = err
+			}
+			iter.reader = nil
+		}
+	}
+
+	return true
+}
+
+// Reads the rest of the input.
+func (iter *StringIter) fillAll() {
+	for iter.fill(iter.end() + 1) {
 	}
 }
 
 func (iter *StringIter) Eof() bool {
-	return iter.pos >= len(iter.s)
+	return !iter.fill(iter.pos + 1)
+}
+
+// Returns the first error returned by the reader, other than io.EOF. The
+// iterator is at eof after a read error.
+func (iter *StringIter) Err() error {
+	return iter.err
 }
 
 func (iter *StringIter) Pos() int {
 	return iter.pos
 }
 
+// Returns the input string. Empty when reading from a reader, use Slice
+// instead.
 func (iter *StringIter) Source() string {
+	if iter.stream {
+		return ""
+	}
+
 	return iter.s
 }
 
+// Returns true if the iterator reads from a reader.
+func (iter *StringIter) Streaming() bool {
+	return iter.stream
+}
+
+// Returns the input between the offsets start and end. The range is cut
+// to what is available.
+func (iter *StringIter) Slice(start int, end int) string {
+	iter.fill(end)
+	start = max(iter.base, start)
+	end = min(iter.end(), end)
+
+	if start >= end {
+		return ""
+	}
+
+	return iter.s[start-iter.base : end-iter.base]
+}
+
+// Drops buffered input before offset to, which can not be accessed after.
+// Has no effect unless reading from a reader.
+func (iter *StringIter) Discard(to int) {
+	to = min(to, iter.end())
+	if !iter.stream || to <= iter.base {
+		return
+	}
+
+	iter.baseLine, iter.baseCol = iter.LineColAt(to)
+	iter.s = iter.s[to-iter.base:]
+	iter.base = to
+
+	i, _ := slices.BinarySearch(iter.lineStarts, to+1)
+	iter.lineStarts = iter.lineStarts[i:]
+}
+
+// Moves pos and peek pos to the given index.
+func (iter *StringIter) SetPos(pos int) {
+	iter.pos = pos
+	iter.peekPos = pos
+}
+
 // Saves pos
 func (iter *StringIter) Push() {
 	iter.posStack = append(iter.posStack, iter.pos)
@@ -53,15 +179,14 @@ func (iter *StringIter) Consume() string {
 	}
 
 	end := iter.peekPos
-	if iter.peekPos >= len(iter.s) {
-		end = len(iter.s)
-	}
-
 	if iter.pos == iter.peekPos {
 		end++
 	}
 
-	s := iter.s[iter.pos:end]
+	iter.fill(end)
+	end = min(end, iter.end())
+
+	s := iter.s[iter.pos-iter.base : end-iter.base]
 	iter.pos = end
 	iter.peekPos = iter.pos
 	return s
@@ -69,12 +194,31 @@ func (iter *StringIter) Consume() string {
 
 // Peeks next character. Returns null byte on eof.
 func (iter *StringIter) Peek() byte {
-	if iter.peekPos >= len(iter.s) {
+	if iter.Eof() {
 		return 0
 	}
 
-	b := iter.s[iter.pos]
-	return b
+	return iter.s[iter.pos-iter.base]
+}
+
+// Peeks next rune and its size in bytes. Returns 0 and size 0 on eof.
+// Invalid UTF-8 is returned as utf8.RuneError with size 1.
+func (iter *StringIter) PeekRune() (r rune, size int) {
+	if iter.Eof() {
+		return 0, 0
+	}
+
+	iter.fill(iter.pos + utf8.UTFMax)
+	return utf8.DecodeRuneInString(iter.s[iter.pos-iter.base:])
+}
+
+// Consumes and returns the next rune, ignoring the peek pointer. Returns
+// empty string on eof.
+func (iter *StringIter) ConsumeRune() string {
+	_, size := iter.PeekRune()
+	s := iter.Slice(iter.pos, iter.pos+size)
+	iter.SetPos(iter.pos + size)
+	return s
 }
 
 // Moves peek pointer by n
@@ -84,17 +228,25 @@ func (iter *StringIter) PeekN(n uint) {
 
 // Moves peek pointer to c. Returns false if c is not found.
 func (iter *StringIter) Seek(c byte) bool {
-	if i := strings.IndexByte(iter.Remainder(), c); i != -1 {
-		iter.peekPos = i + iter.pos
-		return true
-	}
+	from := iter.pos
 
-	return false
+	for {
+		if i := strings.IndexByte(iter.s[from-iter.base:], c); i != -1 {
+			iter.peekPos = from + i
+			return true
+		}
+
+		from = iter.end()
+		if !iter.fill(from + 1) {
+			return false
+		}
+	}
 }
 
 // Moves peek pointer to end of iterator
 func (iter *StringIter) SeekEnd() {
-	iter.peekPos = len(iter.s)
+	iter.fillAll()
+	iter.peekPos = iter.end()
 }
 
 // Returns remaining string from iter pos. Empty string on eof. Does not move pos.
@@ -103,7 +255,8 @@ func (iter *StringIter) Remainder() string {
 		return ""
 	}
 
-	return iter.s[iter.pos:]
+	iter.fillAll()
+	return iter.s[iter.pos-iter.base:]
 }
 
 // Restores peek pos to pos.
@@ -111,8 +264,46 @@ func (iter *StringIter) Restore() {
 	iter.peekPos = iter.pos
 }
 
-// Resets iterator to beginning
+// Returns the line and column of the current pos. See LineColAt.
+func (iter *StringIter) LineCol() (line int, col int) {
+	return iter.LineColAt(iter.pos)
+}
+
+// Returns the line and column of the byte offset pos. Both start at 1, and
+// the column is counted in runes. The line index is only built as far as
+// it is needed.
+func (iter *StringIter) LineColAt(pos int) (line int, col int) {
+	pos = max(iter.base, min(pos, iter.end()))
+	iter.indexed = max(iter.indexed, iter.base)
+
+	for ; iter.indexed < pos; iter.indexed++ {
+		if iter.s[iter.indexed-iter.base] == '\n' {
+			iter.lineStarts = append(iter.lineStarts, iter.indexed+1)
+		}
+	}
+
+	// Number of lines starting after base, at or before pos
+	n, _ := slices.BinarySearch(iter.lineStarts, pos+1)
+	line = iter.baseLine + n
+
+	switch {
+	case line == iter.lastLine && iter.lastPos >= iter.base && pos >= iter.lastPos:
+		col = iter.lastCol + utf8.RuneCountInString(iter.s[iter.lastPos-iter.base:pos-iter.base])
+	case line == iter.lastLine && iter.lastPos >= iter.base:
+		col = iter.lastCol - utf8.RuneCountInString(iter.s[pos-iter.base:iter.lastPos-iter.base])
+	case n == 0:
+		col = iter.baseCol + utf8.RuneCountInString(iter.s[:pos-iter.base])
+	default:
+		start := iter.lineStarts[n-1]
+		col = utf8.RuneCountInString(iter.s[start-iter.base:pos-iter.base]) + 1
+	}
+
+	iter.lastPos, iter.lastLine, iter.lastCol = pos, line, col
+	return line, col
+}
+
+// Resets iterator to beginning, or to the first byte not discarded.
 func (iter *StringIter) Reset() {
-	iter.pos = 0
-	iter.peekPos = 0
+	iter.pos = iter.base
+	iter.peekPos = iter.base
 }
@@ -1,7 +1,11 @@
 package test
 
 import (
+	"errors"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/jesperkha/gokenizer/stringiter"
 )
@@ -63,3 +67,115 @@ func TestStack(t *testing.T) {
 	iter.Pop()
 	assertEq(t, s, iter.Remainder())
 }
+
+func TestRunes(t *testing.T) {
+	s := "Blåbær!"
+	iter := stringiter.New(s)
+
+	iter.PeekN(2)
+	assertEq(t, "Bl", iter.Consume())
+
+	r, size := iter.PeekRune()
+	if r != 'å' || size != 2 {
+		t.Fatalf("expected 'å' of size 2, got '%c' of size %d", r, size)
+	}
+
+	assertEq(t, "å", iter.ConsumeRune())
+	assertEq(t, "b", iter.ConsumeRune())
+	assertEq(t, "æ", iter.ConsumeRune())
+	assertEq(t, "r!", iter.Remainder())
+
+	iter.SeekEnd()
+	iter.Consume()
+
+	if r, size := iter.PeekRune(); r != 0 || size != 0 {
+		t.Fatalf("expected no rune at eof, got '%c' of size %d", r, size)
+	}
+	assertEq(t, "", iter.ConsumeRune())
+}
+
+func TestLineCol(t *testing.T) {
+	iter := stringiter.New("foo\nblåbær bar\n\nbaz")
+
+	cases := []struct{ pos, line, col int }{
+		{0, 1, 1},
+		{3, 1, 4},
+		{4, 2, 1},
+		{13, 2, 8}, // after "blåbær " which contains two 2-byte runes
+		{15, 2, 10},
+		{8, 2, 4},
+		{17, 3, 1},
+		{18, 4, 1},
+		{20, 4, 3},
+		{2, 1, 3},
+	}
+
+	for _, c := range cases {
+		if line, col := iter.LineColAt(c.pos); line != c.line || col != c.col {
+			t.Errorf("pos %d: expected %d:%d, got %d:%d", c.pos, c.line, c.col, line, col)
+		}
+	}
+
+	iter.PeekN(5)
+	iter.Consume()
+	if line, col := iter.LineCol(); line != 2 || col != 2 {
+		t.Errorf("expected 2:2, got %d:%d", line, col)
+	}
+}
+
+func TestReader(t *testing.T) {
+	s := "Hello, world!\nblåbær"
+	iter := stringiter.NewReader(iotest.OneByteReader(strings.NewReader(s)))
+
+	if iter.Source() != "" {
+		t.Fatalf("expected empty source, got '%s'", iter.Source())
+	}
+
+	iter.PeekN(5)
+	assertEq(t, "Hello", iter.Consume())
+
+	if !iter.Seek('\n') {
+		t.Fatal("failed to seek to newline")
+	}
+	assertEq(t, ", world!", iter.Consume())
+
+	iter.Discard(iter.Pos())
+	assertEq(t, "", iter.Slice(0, 5))
+	assertEq(t, "\nbl", iter.Slice(13, 16))
+
+	iter.Consume()
+	if line, col := iter.LineCol(); line != 2 || col != 1 {
+		t.Fatalf("expected 2:1, got %d:%d", line, col)
+	}
+
+	assertEq(t, "b", iter.ConsumeRune())
+	assertEq(t, "l", iter.ConsumeRune())
+	iter.Discard(iter.Pos())
+	assertEq(t, "å", iter.ConsumeRune())
+
+	if line, col := iter.LineCol(); line != 2 || col != 4 {
+		t.Fatalf("expected 2:4, got %d:%d", line, col)
+	}
+
+	assertEq(t, "bær", iter.Remainder())
+	if iter.Pos() != len("Hello, world!\nblå") {
+		t.Fatalf("expected pos %d, got %d", len("Hello, world!\nblå"), iter.Pos())
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	errRead := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("foo"), iotest.ErrReader(errRead))
+	iter := stringiter.NewReader(r)
+
+	iter.PeekN(3)
+	assertEq(t, "foo", iter.Consume())
+
+	if !iter.Eof() {
+		t.Fatal("expected eof after read error")
+	}
+
+	if iter.Err() != errRead {
+		t.Fatalf("expected read error, got %v", iter.Err())
+	}
+}
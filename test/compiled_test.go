@@ -0,0 +1,124 @@
+package test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jesperkha/gokenizer"
+)
+
+func TestCompile(t *testing.T) {
+	tokr := gokenizer.New()
+	output := []string{}
+
+	tokr.Class("key", "{var}")
+	tokr.Pattern("{key}={number}", func(t gokenizer.Token) error {
+		output = append(output, t.Get("key").Get("var").Lexeme)
+		return nil
+	})
+
+	compiled, err := tokr.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes after compiling do not affect the compiled tokenizer
+	tokr.Pattern("{word}", func(t gokenizer.Token) error {
+		output = append(output, "word")
+		return nil
+	})
+	tokr.Class("value", "{number}")
+
+	if err := compiled.Run("foo=1 bar=2 baz"); err != nil {
+		t.Fatal(err)
+	}
+
+	if expect := []string{"foo", "bar"}; slices.Compare(expect, output) != 0 {
+		t.Errorf("expected '%s', got '%s'", strings.Join(expect, "|"), strings.Join(output, "|"))
+	}
+
+	if ok, err := compiled.Matches("a=1", "{key}={number}"); !ok || err != nil {
+		t.Errorf("expected match, got non match and err: %v", err)
+	}
+
+	if _, err := compiled.Matches("1", "{value}"); err == nil {
+		t.Error("expected unknown class error")
+	}
+
+	tokr.Pattern("", nil)
+	if _, err := tokr.Compile(); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestCompileConcurrent(t *testing.T) {
+	tokr := gokenizer.New()
+	count := atomic.Int64{}
+
+	tokr.Class("item", "{number},")
+	tokr.Pattern("[{item*}{number}]", func(tok gokenizer.Token) error {
+		count.Add(1)
+		if tok.GetAt("item", 1).Lexeme != "22," {
+			return fmt.Errorf("expected '22,', got '%s'", tok.GetAt("item", 1).Lexeme)
+		}
+		return nil
+	})
+
+	compiled, err := tokr.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers = 8
+	const runs = 100
+	input := strings.Repeat("[1,22,333] ", 10)
+
+	wg := sync.WaitGroup{}
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for range runs {
+				if err := compiled.Run(input); err != nil {
+					t.Error(err)
+				}
+
+				if err := compiled.RunReader(strings.NewReader(input)); err != nil {
+					t.Error(err)
+				}
+
+				if ok, err := compiled.Matches("[1,2]", "[{item*}{number}]"); !ok || err != nil {
+					t.Errorf("expected match, got non match and err: %v", err)
+				}
+
+				n := 0
+				for tok, err := range compiled.Lex(input).All() {
+					if err != nil {
+						t.Error(err)
+						break
+					}
+					if tok.Lexeme != "[1,22,333]" {
+						t.Errorf("expected '[1,22,333]', got '%s'", tok.Lexeme)
+					}
+					n++
+				}
+
+				if n != 10 {
+					t.Errorf("expected 10 tokens, got %d", n)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if expect := int64(workers * runs * 30); count.Load() != expect {
+		t.Errorf("expected %d matches, got %d", expect, count.Load())
+	}
+}
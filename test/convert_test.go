@@ -0,0 +1,140 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/jesperkha/gokenizer"
+)
+
+func TestConversions(t *testing.T) {
+	tokr := gokenizer.New()
+
+	tokr.Pattern("{number} {float} {word} {hex} {base64} {string}", func(tok gokenizer.Token) error {
+		if n, err := tok.Get("number").Int(); err != nil || n != 42 {
+			t.Errorf("expected 42, got %d, %v", n, err)
+		}
+
+		if f, err := tok.Get("float").Float(); err != nil || f != 1.5 {
+			t.Errorf("expected 1.5, got %f, %v", f, err)
+		}
+
+		if b, err := tok.Get("word").Bool(); err != nil || !b {
+			t.Errorf("expected true, got %v, %v", b, err)
+		}
+
+		if b, err := tok.Get("hex").HexBytes(); err != nil || !bytes.Equal(b, []byte{0xff, 0x00, 0x10}) {
+			t.Errorf("expected ff0010, got %x, %v", b, err)
+		}
+
+		if b, err := tok.Get("base64").Base64Bytes(); err != nil || string(b) != "hi!" {
+			t.Errorf("expected 'hi!', got '%s', %v", b, err)
+		}
+
+		if s, err := tok.Get("string").Unquote(); err != nil || s != "a\tb" {
+			t.Errorf("expected 'a\\tb', got '%s', %v", s, err)
+		}
+
+		return nil
+	})
+
+	if err := tokr.Run(`42 1.5 true #ff0010 aGkh "a\tb"`); err != nil {
+		t.Error(err)
+	}
+
+	// Empty strings used to take the closing quote as content
+	tokr = gokenizer.New()
+	tokr.Pattern("{string}x", func(tok gokenizer.Token) error {
+		if s, err := tok.Get("string").Unquote(); err != nil || s != "" {
+			t.Errorf("expected empty string, got %q, %v", s, err)
+		}
+		return nil
+	})
+
+	if ok, err := tokr.Matches(`""x`, "{string}x"); !ok || err != nil {
+		t.Errorf("expected match, got %v", err)
+	}
+
+	if err := tokr.Run(`""x`); err != nil {
+		t.Error(err)
+	}
+
+	cases := []struct {
+		lexeme string
+		expect string
+	}{
+		{`a\nb`, "a\nb"},
+		{"`hi`", "`hi`"},
+		{`'a'`, `'a'`},
+		{`\"`, `"`},
+	}
+
+	for _, c := range cases {
+		if s, err := (gokenizer.Token{Lexeme: c.lexeme}).Unquote(); err != nil || s != c.expect {
+			t.Errorf("expected %q, got %q, %v", c.expect, s, err)
+		}
+	}
+
+	for _, lexeme := range []string{`"`, `a\`, `\q`} {
+		if _, err := (gokenizer.Token{Lexeme: lexeme}).Unquote(); err == nil {
+			t.Errorf("expected error unquoting %q", lexeme)
+		}
+	}
+
+	for _, lexeme := range []string{"aGk=", "aGk"} {
+		if b, err := (gokenizer.Token{Lexeme: lexeme}).Base64Bytes(); err != nil || string(b) != "hi" {
+			t.Errorf("expected 'hi', got '%s', %v", b, err)
+		}
+	}
+
+	for _, lexeme := range []string{"QQ=", "aGk==", "a=Gk"} {
+		if _, err := (gokenizer.Token{Lexeme: lexeme}).Base64Bytes(); err == nil {
+			t.Errorf("expected error decoding %q", lexeme)
+		}
+	}
+}
+
+func TestConversionError(t *testing.T) {
+	tokr := gokenizer.New()
+
+	var convErr error
+	tokr.Pattern("x={number}", func(tok gokenizer.Token) error {
+		_, convErr = tok.Get("number").Int()
+		return nil
+	})
+
+	if err := tokr.Run("\n  x=99999999999999999999"); err != nil {
+		t.Fatal(err)
+	}
+
+	var cerr *gokenizer.ConversionError
+	if !errors.As(convErr, &cerr) {
+		t.Fatalf("expected conversion error, got %v", convErr)
+	}
+
+	if line, col := cerr.Token.LineCol(); line != 2 || col != 5 || cerr.Type != "int" {
+		t.Errorf("expected int at 2:5, got %s at %d:%d", cerr.Type, line, col)
+	}
+
+	if !errors.Is(convErr, strconv.ErrRange) {
+		t.Errorf("expected range error, got %v", convErr)
+	}
+
+	expect := `gokenizer: cannot convert "99999999999999999999" to int at line 2, column 5: strconv.Atoi: parsing "99999999999999999999": value out of range`
+	if convErr.Error() != expect {
+		t.Errorf("expected '%s', got '%s'", expect, convErr.Error())
+	}
+
+	for _, f := range []func(gokenizer.Token) error{
+		func(tok gokenizer.Token) error { _, err := tok.Float(); return err },
+		func(tok gokenizer.Token) error { _, err := tok.Bool(); return err },
+		func(tok gokenizer.Token) error { _, err := tok.HexBytes(); return err },
+		func(tok gokenizer.Token) error { _, err := tok.Base64Bytes(); return err },
+	} {
+		if err := f(gokenizer.Token{Lexeme: "#x!"}); !errors.As(err, &cerr) {
+			t.Errorf("expected conversion error, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jesperkha/gokenizer"
+)
+
+func TestPatternErrors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		offset  int
+		kind    error
+	}{
+		{"foo{barl", 8, gokenizer.ErrSyntax},
+		{"a {word} {foo} b", 9, gokenizer.ErrUnknownClass},
+		{"{word:1}", 6, gokenizer.ErrInvalidName},
+		{"{number{2,1}}", 8, gokenizer.ErrSyntax},
+		{"{number{2", 8, gokenizer.ErrSyntax},
+		{"a}b", 1, gokenizer.ErrSyntax},
+		{"{word}}", 6, gokenizer.ErrSyntax},
+		{"a{(b|c}", 2, gokenizer.ErrSyntax},
+		{"{(a|{foo})}", 4, gokenizer.ErrUnknownClass},
+		{"{(a)b}", 4, gokenizer.ErrSyntax},
+		{"{[a-z}", 1, gokenizer.ErrSyntax},
+		{"{[]}", 1, gokenizer.ErrSyntax},
+		{"{[z-a]}", 1, gokenizer.ErrSyntax},
+		{`{until ";}`, 7, gokenizer.ErrSyntax},
+		{"{number x}", 8, gokenizer.ErrSyntax},
+		{"a{until}", 1, gokenizer.ErrInvalidArgument},
+		{"{until 2}", 0, gokenizer.ErrInvalidArgument},
+		{`{number ";"}`, 0, gokenizer.ErrInvalidArgument},
+		{"{line 2}", 0, gokenizer.ErrInvalidArgument},
+		{"{foo 2}", 0, gokenizer.ErrUnknownClass},
+		{"a{}b", 2, gokenizer.ErrSyntax},
+		{"{:x}", 1, gokenizer.ErrSyntax},
+	}
+
+	tokr := gokenizer.New()
+
+	for _, tt := range tests {
+		_, err := tokr.Matches("", tt.pattern)
+
+		perr := &gokenizer.PatternError{}
+		if !errors.As(err, &perr) {
+			t.Errorf("%s: expected pattern error, got %v", tt.pattern, err)
+			continue
+		}
+
+		if !errors.Is(err, tt.kind) {
+			t.Errorf("%s: expected kind '%v', got '%v'", tt.pattern, tt.kind, perr.Kind)
+		}
+
+		if perr.Pattern != tt.pattern || perr.Offset != tt.offset {
+			t.Errorf("%s: expected offset %d, got '%s' at %d", tt.pattern, tt.offset, perr.Pattern, perr.Offset)
+		}
+	}
+}
+
+func TestJoinedErrors(t *testing.T) {
+	tokr := gokenizer.New()
+
+	tokr.Pattern("{foo}", func(t gokenizer.Token) error {
+		return nil
+	})
+	tokr.Pattern("bar", nil)
+	tokr.Pattern("{ws}", func(t gokenizer.Token) error {
+		return nil
+	})
+	tokr.Class("1", "a")
+	tokr.Class("word", "a")
+	tokr.Class("empty")
+	tokr.Class("broken", "{a", "{b}")
+	tokr.ClassFunc("number", func(b byte) bool {
+		return true
+	})
+
+	err := tokr.Run("")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	kinds := []error{
+		gokenizer.ErrUnknownClass,
+		gokenizer.ErrNilCallback,
+		gokenizer.ErrEmptyMatch,
+		gokenizer.ErrInvalidName,
+		gokenizer.ErrClassDefined,
+		gokenizer.ErrNoPatterns,
+		gokenizer.ErrSyntax,
+	}
+
+	for _, kind := range kinds {
+		if !errors.Is(err, kind) {
+			t.Errorf("expected error of kind '%v' in '%v'", kind, err)
+		}
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("expected joined errors, got %T", err)
+	}
+
+	if n := len(joined.Unwrap()); n != 9 {
+		t.Errorf("expected 9 errors, got %d", n)
+	}
+
+	// Both patterns of the broken class are reported
+	classErrs := 0
+	for _, err := range joined.Unwrap() {
+		perr := &gokenizer.PatternError{}
+		if errors.As(err, &perr) && perr.Class == "broken" {
+			classErrs++
+		}
+	}
+
+	if classErrs != 2 {
+		t.Errorf("expected 2 errors for class 'broken', got %d", classErrs)
+	}
+
+	if _, err := tokr.Compile(); err == nil {
+		t.Error("expected error from Compile")
+	}
+}
+
+func TestClassFuncNames(t *testing.T) {
+	tokr := gokenizer.New()
+
+	tokr.ClassFunc("c++", func(b byte) bool {
+		return true
+	})
+	tokr.ClassRuneFunc("a b", func(r rune) bool {
+		return true
+	})
+
+	_, err := tokr.Compile()
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 2 {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+
+	for _, err := range joined.Unwrap() {
+		cerr := &gokenizer.ClassError{}
+		if !errors.As(err, &cerr) || !errors.Is(err, gokenizer.ErrInvalidName) {
+			t.Errorf("expected invalid name class error, got %v", err)
+		}
+	}
+}
+
+func TestLeftRecursion(t *testing.T) {
+	tokr := gokenizer.New()
+
+	tokr.Class("list", "{items}")
+	tokr.Class("items", "{ws}{list},{number}", "{number}")
+
+	_, err := tokr.Compile()
+
+	cerr := &gokenizer.ClassError{}
+	if !errors.As(err, &cerr) || !errors.Is(err, gokenizer.ErrLeftRecursion) {
+		t.Fatalf("expected left recursion error, got %v", err)
+	}
+
+	if expect := "gokenizer: class 'list': left recursion: list -> items -> list"; err.Error() != expect {
+		t.Errorf("expected '%s', got '%s'", expect, err.Error())
+	}
+
+	// Right recursion is fine
+	tokr = gokenizer.New()
+	tokr.Class("right", "{number},{right}", "{number}")
+
+	if matched, err := tokr.Matches("1,2,3", "{right}"); err != nil || !matched {
+		t.Errorf("expected match, got %t, %v", matched, err)
+	}
+}
+
+func TestCallbackError(t *testing.T) {
+	errFoo := errors.New("foo")
+
+	tokr := gokenizer.New()
+	tokr.Pattern("{word}={number}", func(t gokenizer.Token) error {
+		if t.Get("word").Lexeme == "foo" {
+			return errFoo
+		}
+		return nil
+	})
+
+	err := tokr.Run("bar=1\n  foo=2")
+	if !errors.Is(err, errFoo) {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+
+	cerr := &gokenizer.CallbackError{}
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected *CallbackError, got %T", err)
+	}
+
+	if cerr.Pattern != "{word}={number}" || cerr.Token.Lexeme != "foo=2" {
+		t.Errorf("expected 'foo=2' matched by '{word}={number}', got '%s' matched by '%s'", cerr.Token.Lexeme, cerr.Pattern)
+	}
+
+	if tok := cerr.Token; tok.Pos != 8 || tok.Line != 2 || tok.Col != 3 {
+		t.Errorf("expected position 8 at 2:3, got %d at %d:%d", tok.Pos, tok.Line, tok.Col)
+	}
+
+	if errors.Unwrap(err) != errFoo {
+		t.Errorf("expected unwrapped error to be the callback error, got %v", errors.Unwrap(err))
+	}
+
+	// Errors from the unmatched handler
+	tokr = gokenizer.New()
+	tokr.Unmatched(func(t gokenizer.Token) error {
+		return errFoo
+	})
+
+	if err := tokr.Run("?"); !errors.As(err, &cerr) || cerr.Pattern != "" || cerr.Err != errFoo {
+		t.Errorf("expected *CallbackError for unmatched input, got %v", err)
+	}
+}
@@ -1,10 +1,13 @@
 package test
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/jesperkha/gokenizer"
 )
@@ -128,8 +131,13 @@ func TestClasses(t *testing.T) {
 		),
 		makeClassTester(
 			"string",
-			[]string{"\"hello\"", "\"foo", "foo\"", "foo\"bar\"faz"},
-			[]string{"\"hello\"", "", "", "\"bar\""},
+			[]string{"\"hello\"", "\"foo", "foo\"", "foo\"bar\"faz", "\"\" x"},
+			[]string{"\"hello\"", "", "", "\"bar\"", "\"\""},
+		),
+		makeClassTester(
+			"line",
+			[]string{"foo\n", "\nbar", "foo"},
+			[]string{"foo\n", "\n", ""},
 		),
 		makeClassTester(
 			"hex",
@@ -141,6 +149,21 @@ func TestClasses(t *testing.T) {
 			[]string{"(aGVsbG8gd29ybGQ=)"},
 			[]string{"aGVsbG8gd29ybGQ="},
 		),
+		makeClassTester(
+			"parens",
+			[]string{"(a (b c) d)", "f(x) (y)", "(a", `(")" "\"")`, `("()`},
+			[]string{"(a (b c) d)", "(y)", "", `(")" "\"")`, "()"},
+		),
+		makeClassTester(
+			"brackets",
+			[]string{"[1, [2, 3], []]", "[[]"},
+			[]string{"[1, [2, 3], []]", "[]"},
+		),
+		makeClassTester(
+			"braces",
+			[]string{`{"a": {"b": "}"}}`, "{ x { y } "},
+			[]string{`{"a": {"b": "}"}}`, "{ y }"},
+		),
 	}
 
 	for i, tt := range tests {
@@ -432,3 +455,932 @@ func TestMatches(t *testing.T) {
 		t.Errorf("expected non-match")
 	}
 }
+
+func TestBacktracking(t *testing.T) {
+	tests := []func(*testing.T){
+		makeTokenizerTester(
+			"foobar baz",
+			[]string{"foobar"},
+			[]string{"{word}bar"},
+		),
+		makeTokenizerTester(
+			"main.go main_test.go",
+			[]string{"main.go", "main_test.go"},
+			[]string{"{text}.go"},
+		),
+		makeTokenizerTester(
+			"foo_test bar",
+			[]string{"foo_test"},
+			[]string{"{var}_test"},
+		),
+		makeTokenizerTester(
+			"abc123456",
+			[]string{"abc123456"},
+			[]string{"{word}{number}56"},
+		),
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("case_%d", i+1), tt)
+	}
+
+	tokr := gokenizer.New()
+	tokr.Class("file", "{word}.{word}")
+
+	if ok, err := tokr.Matches("main.go.go", "{text}.{word}"); !ok || err != nil {
+		t.Errorf("expected match, got non match and err: %v", err)
+	}
+
+	called := false
+	tokr.Pattern("{file}o", func(tok gokenizer.Token) error {
+		called = true
+		if name := tok.Get("file").Get("word").Lexeme; name != "main" {
+			return fmt.Errorf("expected '%s', got '%s'", "main", name)
+		}
+		if ext := tok.Get("file").GetAt("word", 1).Lexeme; ext != "g" {
+			return fmt.Errorf("expected '%s', got '%s'", "g", ext)
+		}
+		return nil
+	})
+
+	if err := tokr.Run("main.go"); err != nil {
+		t.Error(err)
+	}
+
+	if !called {
+		t.Error("expected pattern to match")
+	}
+}
+
+func TestQuantifiers(t *testing.T) {
+	tests := []func(*testing.T){
+		makeTokenizerTester(
+			"12x x 3",
+			[]string{"12x", "x"},
+			[]string{"{number*}x"},
+		),
+		makeTokenizerTester(
+			"-1 2",
+			[]string{"-1", "2"},
+			[]string{"{symbol?}{number}"},
+		),
+		makeTokenizerTester(
+			"abcdefg",
+			[]string{"abcd", "efg"},
+			[]string{"{char{2,4}}"},
+		),
+		makeTokenizerTester(
+			"abcdefg",
+			[]string{"abc", "def"},
+			[]string{"{char{3}}"},
+		),
+		makeTokenizerTester(
+			"a ab abc",
+			[]string{"ab", "abc"},
+			[]string{"{char{2,}}"},
+		),
+		makeTokenizerTester(
+			"aaab",
+			[]string{"aaab"},
+			[]string{"{char*}b"},
+		),
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("case_%d", i+1), tt)
+	}
+
+	tokr := gokenizer.New()
+	called := false
+
+	tokr.Class("item", "{number},")
+	tokr.Pattern("[{item*}{number}]", func(tok gokenizer.Token) error {
+		called = true
+		for i, expect := range []string{"1,", "22,", "333,"} {
+			if got := tok.GetAt("item", i).Lexeme; got != expect {
+				return fmt.Errorf("expected '%s', got '%s'", expect, got)
+			}
+		}
+		if got := tok.GetAt("item", 3).Lexeme; got != "" {
+			return fmt.Errorf("expected no fourth item, got '%s'", got)
+		}
+		if got := tok.Get("number").Lexeme; got != "4" {
+			return fmt.Errorf("expected '%s', got '%s'", "4", got)
+		}
+		return nil
+	})
+
+	if err := tokr.Run("[1,22,333,4]"); err != nil {
+		t.Error(err)
+	}
+
+	if !called {
+		t.Error("expected pattern to match")
+	}
+
+	for _, pattern := range []string{"{number{}}", "{number{2,1}}", "{number{a}}", "{number{0}}", "{number{2}"} {
+		if _, err := tokr.Matches("1", pattern); err == nil {
+			t.Errorf("expected error for pattern '%s'", pattern)
+		}
+	}
+}
+
+func TestLongRepetition(t *testing.T) {
+	tokr := gokenizer.New()
+
+	n := 0
+	tokr.Pattern("{char+}", func(tok gokenizer.Token) error {
+		n = tok.Length
+		return nil
+	})
+
+	// Each repetition used to nest a call, overflowing the stack
+	if err := tokr.Run(strings.Repeat("a", 1_000_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 1_000_000 {
+		t.Errorf("expected one token of length %d, got %d", 1_000_000, n)
+	}
+
+	// Backtracking into a repetition tries the later matches of it
+	if ok, err := tokr.Matches("aabc", "{(a|ab)+}c"); !ok || err != nil {
+		t.Errorf("expected match, got %v", err)
+	}
+}
+
+func TestNamedCaptures(t *testing.T) {
+	tokr := gokenizer.New()
+	called := false
+
+	tokr.Class("edge", "{word:from} -> {word:to}")
+
+	tokr.Pattern("{edge:first}; {number:weights+}", func(tok gokenizer.Token) error {
+		called = true
+		edge := tok.Get("first")
+
+		if got := edge.Get("from").Lexeme; got != "a" {
+			return fmt.Errorf("expected '%s', got '%s'", "a", got)
+		}
+		if got := edge.Get("to").Lexeme; got != "b" {
+			return fmt.Errorf("expected '%s', got '%s'", "b", got)
+		}
+
+		// Class names are still available
+		if got := edge.GetAt("word", 1).Lexeme; got != "b" {
+			return fmt.Errorf("expected '%s', got '%s'", "b", got)
+		}
+		if got := tok.Get("edge").Lexeme; got != "a -> b" {
+			return fmt.Errorf("expected '%s', got '%s'", "a -> b", got)
+		}
+
+		if got := tok.GetAt("weights", 0).Lexeme; got != "12" {
+			return fmt.Errorf("expected '%s', got '%s'", "12", got)
+		}
+		return nil
+	})
+
+	if err := tokr.Run("a -> b; 12"); err != nil {
+		t.Error(err)
+	}
+
+	if !called {
+		t.Error("expected pattern to match")
+	}
+
+	for _, pattern := range []string{"{word:}", "{word:12}", "{word:a b}"} {
+		if _, err := tokr.Matches("a", pattern); err == nil {
+			t.Errorf("expected error for pattern '%s'", pattern)
+		}
+	}
+}
+
+func TestEmptyMatch(t *testing.T) {
+	for _, pattern := range []string{"{ws}", "{opt}", "{number*}", "{opt}{ws}"} {
+		tokr := gokenizer.New()
+		tokr.ClassOptional("opt", "a")
+
+		tokr.Pattern(pattern, func(t gokenizer.Token) error {
+			return nil
+		})
+
+		if err := tokr.Run("b"); err == nil {
+			t.Errorf("expected error for pattern '%s'", pattern)
+		}
+	}
+
+	// Optional parts are fine as long as something is consumed
+	tests := []func(*testing.T){
+		makeTokenizerTester(
+			"a b  c",
+			[]string{"a", " b", "  c"},
+			[]string{"{ws}{word}"},
+		),
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("case_%d", i+1), tt)
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	tests := []func(*testing.T){
+		makeClassTester(
+			"word",
+			[]string{"Blåbær", "123Straße!", "Ærlig talt"},
+			[]string{"Blåbær", "Straße", "talt"},
+		),
+		makeClassTester(
+			"var",
+			[]string{"$første_verdi"},
+			[]string{"$første_verdi"},
+		),
+		makeClassTester(
+			"char",
+			[]string{"ø", "1æ"},
+			[]string{"ø", "æ"},
+		),
+		makeClassTester(
+			"symbol",
+			[]string{"a§", "£", "€", "«"},
+			[]string{"§", "£", "€", "«"},
+		),
+		makeClassTester(
+			"text",
+			[]string{"  høyre "},
+			[]string{"høyre"},
+		),
+		makeTokenizerTester(
+			"å ø æ",
+			[]string{"å", "ø", "æ"},
+			[]string{"{char}"},
+		),
+		makeTokenizerTester(
+			"smørbrød",
+			[]string{"smørbrød"},
+			[]string{"{word}brød"},
+		),
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("case_%d", i+1), tt)
+	}
+
+	tokr := gokenizer.New()
+	tokr.ClassRuneFunc("vowel", func(r rune) bool {
+		return strings.ContainsRune("aeiouyæøå", r)
+	})
+	tokr.ClassFunc("nonAscii", func(b byte) bool {
+		return b >= 0x80
+	})
+
+	if ok, err := tokr.Matches("øya", "{vowel}"); !ok || err != nil {
+		t.Errorf("expected match, got non match and err: %v", err)
+	}
+	if ok, err := tokr.Matches("blå", "bl{nonAscii}"); !ok || err != nil {
+		t.Errorf("expected match, got non match and err: %v", err)
+	}
+	if ok, err := tokr.Matches("blå", "bl{vowel}"); !ok || err != nil {
+		t.Errorf("expected match, got non match and err: %v", err)
+	}
+
+	// Byte checkers see the input bytes of invalid UTF-8, such as latin-1
+	tokr.ClassFunc("latin", func(b byte) bool {
+		return b >= 0xC0
+	})
+
+	if ok, err := tokr.Matches("bl\xe5", "bl{latin}"); !ok || err != nil {
+		t.Errorf("expected match, got non match and err: %v", err)
+	}
+	if ok, err := tokr.Matches("bl\xef\xbf\xbd", "bl{latin}"); ok || err != nil {
+		t.Errorf("expected non match, got match and err: %v", err)
+	}
+	if ok, err := tokr.Matches("\xff", "{vowel}"); ok || err != nil {
+		t.Errorf("expected non match, got match and err: %v", err)
+	}
+}
+
+func TestLineInfo(t *testing.T) {
+	input := "foo = 1\nbår = 22\n  baz = 333"
+	expect := []string{"1:1 1:7", "2:1 2:7", "3:3 3:9"}
+
+	run := func(tokr gokenizer.Tokenizer, pos func(gokenizer.Token) (int, int)) {
+		output := []string{}
+
+		tokr.Pattern("{word} = {number}", func(tok gokenizer.Token) error {
+			line, col := pos(tok)
+			nline, ncol := pos(tok.Get("number"))
+			output = append(output, fmt.Sprintf("%d:%d %d:%d", line, col, nline, ncol))
+			return nil
+		})
+
+		if err := tokr.Run(input); err != nil {
+			t.Fatal(err)
+		}
+
+		if slices.Compare(expect, output) != 0 {
+			t.Errorf("expected '%s', got '%s'", strings.Join(expect, "|"), strings.Join(output, "|"))
+		}
+	}
+
+	run(gokenizer.New(), func(tok gokenizer.Token) (int, int) {
+		return tok.Line, tok.Col
+	})
+
+	lazy := gokenizer.New()
+	lazy.LazyLineInfo()
+
+	run(lazy, func(tok gokenizer.Token) (int, int) {
+		if tok.Line != 0 {
+			t.Errorf("expected no line info, got line %d", tok.Line)
+		}
+		return tok.LineCol()
+	})
+}
+
+func TestLineInfoLongLine(t *testing.T) {
+	tokr := gokenizer.New()
+
+	n, col := 0, 0
+	tokr.Pattern("{word}", func(tok gokenizer.Token) error {
+		n++
+		col = tok.Col
+		return nil
+	})
+
+	// Columns used to be counted from the start of the line for every token
+	start := time.Now()
+	if err := tokr.Run(strings.Repeat("ab ", 80000)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 80000 || col != 3*79999+1 {
+		t.Errorf("expected %d tokens, the last at column %d, got %d at column %d", 80000, 3*79999+1, n, col)
+	}
+
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("expected linear time, took %s", d)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	tokr := gokenizer.New()
+	tokr.Strict()
+
+	tokr.Pattern("{word}", func(t gokenizer.Token) error {
+		return nil
+	})
+	tokr.Pattern(" ", func(t gokenizer.Token) error {
+		return nil
+	})
+
+	if err := tokr.Run("foo bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := tokr.Run("foo\nbar ø€ baz")
+	unmatched := &gokenizer.UnmatchedError{}
+	if !errors.As(err, &unmatched) {
+		t.Fatalf("expected unmatched error, got %v", err)
+	}
+
+	if tok := unmatched.Token; tok.Lexeme != "\n" || tok.Line != 1 || tok.Col != 4 {
+		t.Errorf("expected newline at 1:4, got %q at %d:%d", tok.Lexeme, tok.Line, tok.Col)
+	}
+
+	tokr.Pattern("\n", func(t gokenizer.Token) error {
+		return nil
+	})
+
+	err = tokr.Run("foo\nbar ø€ baz")
+	if !errors.As(err, &unmatched) {
+		t.Fatalf("expected unmatched error, got %v", err)
+	}
+
+	if tok := unmatched.Token; tok.Lexeme != "€" || tok.Line != 2 || tok.Col != 6 {
+		t.Errorf("expected '€' at 2:6, got %q at %d:%d", tok.Lexeme, tok.Line, tok.Col)
+	}
+}
+
+func TestUnmatched(t *testing.T) {
+	input := "foo 12+3 bar!? 4"
+	expect := []string{"foo", " ", "+", " ", "bar", "!? "}
+	output := []string{}
+
+	tokr := gokenizer.New()
+
+	tokr.Pattern("{word}", func(t gokenizer.Token) error {
+		output = append(output, t.Lexeme)
+		return nil
+	})
+
+	tokr.Pattern("{number}", func(t gokenizer.Token) error {
+		return nil
+	})
+
+	tokr.Unmatched(func(t gokenizer.Token) error {
+		output = append(output, t.Lexeme)
+		return nil
+	})
+
+	if err := tokr.Run(input); err != nil {
+		t.Fatal(err)
+	}
+
+	if slices.Compare(expect, output) != 0 {
+		t.Errorf("expected '%s', got '%s'", strings.Join(expect, "|"), strings.Join(output, "|"))
+	}
+
+	// Trailing unmatched input
+	tokr = gokenizer.New()
+	tokr.Unmatched(func(tok gokenizer.Token) error {
+		if tok.Lexeme != "æøå" || tok.Pos != 0 || tok.Length != 6 {
+			t.Errorf("expected 'æøå' at 0 with length 6, got '%s' at %d with length %d", tok.Lexeme, tok.Pos, tok.Length)
+		}
+		return fmt.Errorf("unmatched")
+	})
+
+	if err := tokr.Run("æøå"); err == nil {
+		t.Error("expected error from unmatched handler")
+	}
+}
+
+func TestDefinitionOrder(t *testing.T) {
+	output := []string{}
+
+	tokr := gokenizer.New()
+
+	// Classes are used before they are defined
+	tokr.Pattern("{declaration};", func(t gokenizer.Token) error {
+		output = append(output, t.Get("declaration").Get("value").Lexeme)
+		return nil
+	})
+
+	tokr.Class("declaration", "var {word} = {value}")
+	tokr.Class("value", "{number}", "{string}")
+
+	if err := tokr.Run(`var foo = 123; var bar = "baz";`); err != nil {
+		t.Fatal(err)
+	}
+
+	if expect := []string{"123", `"baz"`}; slices.Compare(expect, output) != 0 {
+		t.Errorf("expected '%s', got '%s'", strings.Join(expect, "|"), strings.Join(output, "|"))
+	}
+}
+
+func TestRecursiveClasses(t *testing.T) {
+	tokr := gokenizer.New()
+
+	// Mutually recursive expression grammar
+	tokr.Class("expr", "{term}+{expr}", "{term}")
+	tokr.Class("term", "{number}", "{group}")
+	tokr.Class("group", "({expr})")
+
+	tests := []struct {
+		input string
+		match bool
+	}{
+		{"1", true},
+		{"1+2", true},
+		{"(1+2)+3", true},
+		{"1+((2+3)+(4))", true},
+		{"(1+2", false},
+		{"1+", false},
+		{"()", false},
+	}
+
+	for _, tt := range tests {
+		matched, err := tokr.Matches(tt.input, "{expr}")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if matched != tt.match {
+			t.Errorf("%s: expected match to be %t", tt.input, tt.match)
+		}
+	}
+
+	// Nested values
+	tokr.Pattern("{expr}", func(tok gokenizer.Token) error {
+		group := tok.Get("expr").Get("term").Get("group")
+		if expect, got := "2+3", group.Get("expr").Lexeme; got != expect {
+			t.Errorf("expected '%s', got '%s'", expect, got)
+		}
+		return nil
+	})
+
+	if err := tokr.Run("(2+3)+4"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	tokr := gokenizer.New()
+	tokr.Class("nested", "({nested})", "x")
+	tokr.Pattern("{nested}", func(t gokenizer.Token) error {
+		return nil
+	})
+
+	deep := strings.Repeat("(", 50) + "x" + strings.Repeat(")", 50)
+
+	if err := tokr.Run(deep); err != nil {
+		t.Fatal(err)
+	}
+
+	tokr.MaxDepth(10)
+
+	if err := tokr.Run("((x))" + deep); !errors.Is(err, gokenizer.ErrMaxDepth) {
+		t.Errorf("expected max depth error, got %v", err)
+	}
+
+	if _, err := tokr.Matches(deep, "{nested}"); !errors.Is(err, gokenizer.ErrMaxDepth) {
+		t.Errorf("expected max depth error, got %v", err)
+	}
+
+	// Sequences of a class are not nested
+	if matched, err := tokr.Matches(strings.Repeat("(x)", 50), "{nested+}"); err != nil || !matched {
+		t.Errorf("expected match, got %t, %v", matched, err)
+	}
+
+	// A depth below 1 removes the limit
+	for _, depth := range []int{0, -5} {
+		tokr.MaxDepth(depth)
+
+		if matched, err := tokr.Matches(deep, "{nested}"); err != nil || !matched {
+			t.Errorf("depth %d: expected match, got %t, %v", depth, matched, err)
+		}
+	}
+}
+
+func TestEscapedBraces(t *testing.T) {
+	tests := []struct {
+		input   string
+		pattern string
+	}{
+		{"{", "{{"},
+		{"}", "}}"},
+		{"{{foo}}", "{{{{{word}}}}}"},
+		{"{foo: 1}", "{{{word}: {number}}}"},
+		{"{word}", "{{word}}"},
+		{"map[string]{}", "map[{word}]{{}}"},
+	}
+
+	tokr := gokenizer.New()
+
+	for _, tt := range tests {
+		matched, err := tokr.Matches(tt.input, tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !matched {
+			t.Errorf("expected '%s' to match '%s'", tt.pattern, tt.input)
+		}
+	}
+}
+
+func TestInlineGroups(t *testing.T) {
+	tests := []struct {
+		input   string
+		pattern string
+		match   bool
+	}{
+		{"GET /", "{(GET|POST)} {text}", true},
+		{"POST /", "{(GET|POST)} {text}", true},
+		{"PUT /", "{(GET|POST)} {text}", false},
+		{"-12", "{(-)?}{number}", true},
+		{"12", "{(-)?}{number}", true},
+		{"1.5", "{number}{(.{number})?}", true},
+		{"a,b,c", "{word}{(,{word})*}", true},
+		{"a|b", `{word}{(\|)}{word}`, true},
+		{"f(x)", `{word}{(\({word}\))}`, true},
+		{"abab", "{(ab){2}}", true},
+		{"abc", "{(a|ab)}c", true},
+		{"abc", "{(a|b)}c", false},
+		{"abc", "{(a|ab)}{(c|bc)}", true},
+	}
+
+	tokr := gokenizer.New()
+
+	for _, tt := range tests {
+		matched, err := tokr.Matches(tt.input, tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if matched != tt.match {
+			t.Errorf("%s: expected match of '%s' to be %t", tt.pattern, tt.input, tt.match)
+		}
+	}
+
+	tokr.Pattern("{(GET|POST):method} {(v{number}|latest):version}", func(tok gokenizer.Token) error {
+		if expect, got := "POST", tok.Get("method").Lexeme; got != expect {
+			t.Errorf("expected '%s', got '%s'", expect, got)
+		}
+		if expect, got := "2", tok.Get("version").Get("number").Lexeme; got != expect {
+			t.Errorf("expected '%s', got '%s'", expect, got)
+		}
+		if expect, got := "POST", tok.Get("(GET|POST)").Lexeme; got != expect {
+			t.Errorf("expected '%s', got '%s'", expect, got)
+		}
+		return nil
+	})
+
+	if err := tokr.Run("POST v2"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCharacterSets(t *testing.T) {
+	tests := []struct {
+		input   string
+		pattern string
+		match   bool
+	}{
+		{"a", "{[a-z]}", true},
+		{"ab", "{[a-z]}", false},
+		{"foo_bar-1", "{[a-z0-9_-]+}", true},
+		{"Foo", "{[a-z0-9_-]+}", false},
+		{"key=value", "{[^=]+}={[^;]+}", true},
+		{"a;b", "{[^;]+}", false},
+		{"æøå", "{[æøå]+}", true},
+		{"ff", "{[0-9a-f]{2}}", true},
+		{"fff", "{[0-9a-f]{2}}", false},
+		{"fff", "{[0-9a-f]{2,}}", true},
+		{"", "{[a]*}", true},
+		{"a]b", `{[a\]b]+}`, true},
+		{"a^", `{[\^a]+}`, true},
+		{"foo.go", "{[a-z.]+}.go", true},
+	}
+
+	tokr := gokenizer.New()
+
+	for _, tt := range tests {
+		matched, err := tokr.Matches(tt.input, tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if matched != tt.match {
+			t.Errorf("%s: expected match of '%s' to be %t", tt.pattern, tt.input, tt.match)
+		}
+	}
+
+	tokr.Pattern("{[a-z]:key+}={[^;]:value+};", func(tok gokenizer.Token) error {
+		if expect, got := "foo", tok.Get("key").Lexeme; got != expect {
+			t.Errorf("expected '%s', got '%s'", expect, got)
+		}
+		if expect, got := "bar baz", tok.Get("[^;]").Lexeme; got != expect {
+			t.Errorf("expected '%s', got '%s'", expect, got)
+		}
+		return nil
+	})
+
+	if err := tokr.Run("foo=bar baz;"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClassArguments(t *testing.T) {
+	tests := []struct {
+		input   string
+		pattern string
+		match   bool
+	}{
+		{"foo bar;", `{until ";"};`, true},
+		{"foo bar", `{until ";"}`, false},
+		{";", `{until ";"};`, false},
+		{"a -> b", `{until " -> "} -> {word}`, true},
+		{`"a", "b"`, `"{until "\""}", "{not "\""}"`, true},
+		{"foo bar", `{not "\""}`, true},
+		{`foo"`, `{not "\""}`, false},
+		{"1234", "{number 4}", true},
+		{"123", "{number 4}", false},
+		{"12345", "{number 4}", false},
+		{"ab", "{hex 2,8}", true},
+		{"a", "{hex 2,8}", false},
+		{"abcdef01", "{hex 2,8}", true},
+		{"abcdef012", "{hex 2,8}", false},
+		{"1234", "{number 2}{number 2}", true},
+		{"abc", "{word 2,}", true},
+		{"ab12", "{word 2:w}{number 1,:n}", true},
+		{"", "{ws 0,1}", true},
+	}
+
+	tokr := gokenizer.New()
+
+	for _, tt := range tests {
+		matched, err := tokr.Matches(tt.input, tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if matched != tt.match {
+			t.Errorf("%s: expected match of '%s' to be %t", tt.pattern, tt.input, tt.match)
+		}
+	}
+
+	tokr.Pattern(`{until "="}={not ";"};`, func(tok gokenizer.Token) error {
+		if expect, got := "key", tok.Get("until").Lexeme; got != expect {
+			t.Errorf("expected '%s', got '%s'", expect, got)
+		}
+		if expect, got := "value", tok.Get("not").Lexeme; got != expect {
+			t.Errorf("expected '%s', got '%s'", expect, got)
+		}
+		return nil
+	})
+
+	if err := tokr.Run("key=value;"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLongestMatch(t *testing.T) {
+	output := []string{}
+
+	tokr := gokenizer.New()
+	tokr.LongestMatch()
+
+	tokr.Pattern("if", func(t gokenizer.Token) error {
+		output = append(output, "keyword:"+t.Lexeme)
+		return nil
+	})
+
+	tokr.Pattern("{var}", func(t gokenizer.Token) error {
+		output = append(output, "ident:"+t.Lexeme)
+		return nil
+	})
+
+	tokr.Pattern("=", func(t gokenizer.Token) error {
+		output = append(output, "assign:"+t.Lexeme)
+		return nil
+	})
+
+	tokr.Pattern("==", func(t gokenizer.Token) error {
+		output = append(output, "equal:"+t.Lexeme)
+		return nil
+	})
+
+	tokr.PatternPriority("else", 1, func(t gokenizer.Token) error {
+		output = append(output, "keyword:"+t.Lexeme)
+		return nil
+	})
+
+	if err := tokr.Run("if iffy == x else y = elsewhere"); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{
+		"keyword:if", "ident:iffy", "equal:==", "ident:x", "keyword:else",
+		"ident:y", "assign:=", "ident:elsewhere",
+	}
+
+	if slices.Compare(expect, output) != 0 {
+		t.Errorf("expected '%s', got '%s'", strings.Join(expect, "|"), strings.Join(output, "|"))
+	}
+}
+
+func TestTokenChildren(t *testing.T) {
+	tokr := gokenizer.New()
+
+	tokr.Class("value", "{number}", "{string}")
+	tokr.Class("pair", "{word:key}={value}")
+
+	called := false
+	tokr.Pattern("{pair}, {(x|y)}{[0-9]+}", func(tok gokenizer.Token) error {
+		called = true
+
+		if tok.Class != "" || len(tok.Children) != 3 {
+			t.Fatalf("expected 3 children of a pattern token, got '%s' with %d", tok.Class, len(tok.Children))
+		}
+
+		classes := []string{}
+		for _, child := range tok.Children {
+			classes = append(classes, child.Class)
+		}
+
+		if expect := []string{"pair", "(x|y)", "[0-9]"}; slices.Compare(expect, classes) != 0 {
+			t.Errorf("expected '%s', got '%s'", strings.Join(expect, "|"), strings.Join(classes, "|"))
+		}
+
+		pair := tok.Get("pair")
+		if key := pair.Children[0]; key.Class != "word" || key.Capture != "key" || key.Lexeme != "foo" {
+			t.Errorf("expected word 'foo' captured as key, got %s '%s' captured as '%s'", key.Class, key.Lexeme, key.Capture)
+		}
+
+		if value := pair.Get("value"); !value.Has("string") || value.Has("number") || !pair.Has("key") {
+			t.Errorf("expected value to be a string")
+		}
+
+		if tok.Has("value") {
+			t.Errorf("expected only direct children to be found")
+		}
+		return nil
+	})
+
+	if err := tokr.Run(`foo="bar", x12`); err != nil {
+		t.Error(err)
+	}
+
+	if !called {
+		t.Error("expected pattern to match")
+	}
+}
+
+func TestTokenFind(t *testing.T) {
+	tokr := gokenizer.New()
+
+	tokr.Class("pair", "{word:key}={number:value}")
+	tokr.Class("list", "[{pair}{(, {pair})*}]")
+
+	called := false
+	tokr.Pattern("{list}", func(tok gokenizer.Token) error {
+		called = true
+
+		if key, ok := tok.Find("list.pair.key"); !ok || key.Lexeme != "a" {
+			t.Errorf("expected key 'a', got '%s'", key.Lexeme)
+		}
+
+		if value, ok := tok.Find("list.pair.number"); !ok || value.Lexeme != "1" {
+			t.Errorf("expected value '1', got '%s'", value.Lexeme)
+		}
+
+		for _, path := range []string{"list.pair.foo", "pair", "list..pair", ""} {
+			if _, ok := tok.Find(path); ok {
+				t.Errorf("expected path '%s' not to be found", path)
+			}
+		}
+
+		keys := []string{}
+		for _, key := range tok.FindAll("key") {
+			keys = append(keys, key.Lexeme)
+		}
+
+		if expect := []string{"a", "b", "c"}; slices.Compare(expect, keys) != 0 {
+			t.Errorf("expected keys '%s', got '%s'", strings.Join(expect, "|"), strings.Join(keys, "|"))
+		}
+
+		// Skipping the pairs leaves the list, the groups and their pairs
+		classes := []string{}
+		tok.Walk(func(tok gokenizer.Token) bool {
+			classes = append(classes, tok.Class)
+			return tok.Class != "pair"
+		})
+
+		if expect := []string{"", "list", "pair", "(, {pair})", "pair", "(, {pair})", "pair"}; slices.Compare(expect, classes) != 0 {
+			t.Errorf("expected walk '%s', got '%s'", strings.Join(expect, "|"), strings.Join(classes, "|"))
+		}
+
+		return nil
+	})
+
+	if err := tokr.Run("[a=1, b=2, c=3]"); err != nil {
+		t.Error(err)
+	}
+
+	if !called {
+		t.Error("expected pattern to match")
+	}
+}
+
+func TestTokenDump(t *testing.T) {
+	tokr := gokenizer.New()
+
+	tokr.Class("pair", "{word:key}={number}")
+
+	var tok gokenizer.Token
+	tokr.Pattern("{pair}, {number}", func(t gokenizer.Token) error {
+		tok = t
+		return nil
+	})
+
+	if err := tokr.Run("a=1, 2"); err != nil {
+		t.Fatal(err)
+	}
+
+	dump := strings.Join([]string{
+		`{pair}, {number} "a=1, 2" 1:1`,
+		`  pair "a=1" 1:1`,
+		`    word:key "a" 1:1`,
+		`    number "1" 1:3`,
+		`  number "2" 1:6`,
+		``,
+	}, "\n")
+
+	if s := tok.Dump(); s != dump {
+		t.Errorf("expected dump\n%s\ngot\n%s", dump, s)
+	}
+
+	b, err := json.Marshal(tok.Get("pair"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := `{"class":"pair","lexeme":"a=1","span":{"pos":0,"length":3,"line":1,"col":1},"children":[` +
+		`{"class":"word","capture":"key","lexeme":"a","span":{"pos":0,"length":1,"line":1,"col":1}},` +
+		`{"class":"number","lexeme":"1","span":{"pos":2,"length":1,"line":1,"col":3}}]}`
+
+	if string(b) != expect {
+		t.Errorf("expected json\n%s\ngot\n%s", expect, b)
+	}
+}
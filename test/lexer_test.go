@@ -0,0 +1,132 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jesperkha/gokenizer"
+)
+
+func newLexTokenizer(called *[]string) gokenizer.Tokenizer {
+	tokr := gokenizer.New()
+
+	for _, p := range []string{"{number}", "{word}", "{symbol}"} {
+		tokr.Pattern(p, func(t gokenizer.Token) error {
+			*called = append(*called, t.Lexeme)
+			return nil
+		})
+	}
+
+	return tokr
+}
+
+func TestLexerNext(t *testing.T) {
+	called := []string{}
+	tokr := newLexTokenizer(&called)
+	lex := tokr.Lex("foo = 12;")
+
+	expect := []struct{ lexeme, pattern string }{
+		{"foo", "{word}"},
+		{"=", "{symbol}"},
+		{"12", "{number}"},
+		{";", "{symbol}"},
+	}
+
+	for _, e := range expect {
+		peeked, err := lex.Peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tok, err := lex.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tok.Lexeme != e.lexeme || tok.Pattern != e.pattern {
+			t.Errorf("expected '%s' from '%s', got '%s' from '%s'", e.lexeme, e.pattern, tok.Lexeme, tok.Pattern)
+		}
+
+		if peeked.Lexeme != tok.Lexeme || peeked.Pos != tok.Pos {
+			t.Errorf("expected peeked token to equal next token, got '%s' and '%s'", peeked.Lexeme, tok.Lexeme)
+		}
+	}
+
+	for range 2 {
+		if _, err := lex.Next(); err != io.EOF {
+			t.Errorf("expected io.EOF, got %v", err)
+		}
+	}
+
+	if expect := []string{"foo", "=", "12", ";"}; slices.Compare(expect, called) != 0 {
+		t.Errorf("expected '%s', got '%s'", strings.Join(expect, "|"), strings.Join(called, "|"))
+	}
+}
+
+func TestLexerAll(t *testing.T) {
+	called := []string{}
+	tokr := newLexTokenizer(&called)
+
+	output := []string{}
+	for tok, err := range tokr.Lex("a+1 b").All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		output = append(output, tok.Lexeme)
+	}
+
+	if expect := []string{"a", "+", "1", "b"}; slices.Compare(expect, output) != 0 {
+		t.Errorf("expected '%s', got '%s'", strings.Join(expect, "|"), strings.Join(output, "|"))
+	}
+
+	// Stopping early
+	for tok := range tokr.Lex("a b c").All() {
+		if tok.Lexeme != "a" {
+			t.Errorf("expected 'a', got '%s'", tok.Lexeme)
+		}
+		break
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tokr := gokenizer.New()
+	errFoo := errors.New("foo")
+
+	tokr.Pattern("foo", func(t gokenizer.Token) error {
+		return errFoo
+	})
+	tokr.Pattern("{word}", func(t gokenizer.Token) error {
+		return nil
+	})
+	tokr.Unmatched(func(t gokenizer.Token) error {
+		return nil
+	})
+
+	output := []string{}
+	var lastErr error
+
+	for tok, err := range tokr.Lex("bar, foo baz").All() {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		output = append(output, fmt.Sprintf("%s:%s", tok.Pattern, tok.Lexeme))
+	}
+
+	if expect := []string{"{word}:bar", ":, "}; slices.Compare(expect, output) != 0 {
+		t.Errorf("expected '%s', got '%s'", strings.Join(expect, "|"), strings.Join(output, "|"))
+	}
+
+	if !errors.Is(lastErr, errFoo) {
+		t.Errorf("expected callback error, got %v", lastErr)
+	}
+
+	tokr.Pattern("", nil)
+	if _, err := tokr.Lex("foo").Next(); err == nil || err == io.EOF {
+		t.Errorf("expected error, got %v", err)
+	}
+}
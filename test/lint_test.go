@@ -0,0 +1,125 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/jesperkha/gokenizer"
+)
+
+func TestLint(t *testing.T) {
+	noop := func(gokenizer.Token) error {
+		return nil
+	}
+
+	tokr := gokenizer.New()
+
+	tokr.Class("value", "{text}", "{number}")
+	tokr.Class("prefix", "a", "ab")
+	tokr.Class("spacing", "{ws}", "x")
+	tokr.ClassOptional("sign", "-", "+")
+	tokr.Class("unused", "x")
+	tokr.Class("alsoUnused", "{unused}")
+	tokr.Class("expr", "{number}+{expr}", "{number}")
+
+	tokr.Pattern("{value}{prefix}{spacing}{sign}{expr}", noop)
+	tokr.Pattern("{text}", noop)
+	tokr.Pattern("foo", noop)
+	tokr.Pattern("{word}", noop)
+
+	warnings, err := tokr.Lint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []gokenizer.Warning{
+		{Pattern: "foo", Kind: gokenizer.WarnPossiblyUnreachable},
+		{Pattern: "{word}", Kind: gokenizer.WarnPossiblyUnreachable},
+		{Pattern: "{number}", Class: "value", Kind: gokenizer.WarnPossiblyUnreachable},
+		{Pattern: "{ws}", Class: "spacing", Kind: gokenizer.WarnEmptyAlternative},
+		{Class: "unused", Kind: gokenizer.WarnUnusedClass},
+		{Class: "alsoUnused", Kind: gokenizer.WarnUnusedClass},
+	}
+
+	if len(warnings) != len(expect) {
+		t.Fatalf("expected %d warnings, got %d: %v", len(expect), len(warnings), warnings)
+	}
+
+	for i, w := range warnings {
+		if w.Pattern != expect[i].Pattern || w.Class != expect[i].Class || w.Kind != expect[i].Kind {
+			t.Errorf("expected %v, got %v", expect[i], w)
+		}
+	}
+
+	if expect, got := "gokenizer: pattern 'foo': possibly never used, as '{text}' matches all samples first", warnings[0].String(); got != expect {
+		t.Errorf("expected '%s', got '%s'", expect, got)
+	}
+
+	// Specific patterns first is fine
+	tokr = gokenizer.New()
+	tokr.Pattern("foo", noop)
+	tokr.Pattern("{word}", noop)
+	tokr.Pattern("{var}", noop)
+	tokr.Pattern("{number}", noop)
+	tokr.Pattern("{float}", noop)
+
+	if warnings, err := tokr.Lint(); err != nil || len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v, %v", warnings, err)
+	}
+
+	// The empty alternative added by ClassOptional is not reported
+	tokr = gokenizer.New()
+	tokr.ClassOptional("sp", "{ws}")
+	tokr.Pattern("a{sp}b", noop)
+
+	warnings, err = tokr.Lint()
+	if err != nil || len(warnings) != 1 || warnings[0].Kind != gokenizer.WarnEmptyAlternative || warnings[0].Pattern != "{ws}" {
+		t.Errorf("expected only empty alternative warning for '{ws}', got %v, %v", warnings, err)
+	}
+
+	// Ties in longest match mode
+	tokr = gokenizer.New()
+	tokr.LongestMatch()
+	tokr.Pattern("{word}", noop)
+	tokr.Pattern("if", noop)
+	tokr.PatternPriority("else", 1, noop)
+
+	warnings, err = tokr.Lint()
+	if err != nil || len(warnings) != 1 || warnings[0].Pattern != "if" {
+		t.Errorf("expected warning for 'if', got %v, %v", warnings, err)
+	}
+}
+
+func TestLintFalsePositives(t *testing.T) {
+	noop := func(gokenizer.Token) error {
+		return nil
+	}
+
+	tests := []struct {
+		patterns []string
+		input    string
+	}{
+		{[]string{"{[a-zA-Zæ]+}", "{word}"}, "ø"},
+		{[]string{"{[^x]+}", "{until \";\"};"}, "x;"},
+	}
+
+	for _, tt := range tests {
+		tokr := gokenizer.New()
+		tokr.Pattern(tt.patterns[0], noop)
+
+		reached := false
+		tokr.Pattern(tt.patterns[1], func(gokenizer.Token) error {
+			reached = true
+			return nil
+		})
+
+		// Only the samples are checked, so the warning is a possibility
+		warnings, err := tokr.Lint()
+		if err != nil || len(warnings) != 1 || warnings[0].Kind != gokenizer.WarnPossiblyUnreachable {
+			t.Errorf("%s: expected possibly unreachable warning, got %v, %v", tt.patterns[1], warnings, err)
+		}
+
+		if err := tokr.Run(tt.input); err != nil || !reached {
+			t.Errorf("%s: expected pattern to be used for '%s', got %v", tt.patterns[1], tt.input, err)
+		}
+	}
+}
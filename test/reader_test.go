@@ -0,0 +1,148 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/jesperkha/gokenizer"
+)
+
+func TestRunReader(t *testing.T) {
+	input := "foo = 1\nbår = 22\n  baz = 333\n"
+	expect := []string{"foo 0 1:1", "bår 8 2:1", "baz 20 3:3"}
+	output := []string{}
+
+	tokr := gokenizer.New()
+
+	tokr.Pattern("{word} = {number}\n", func(tok gokenizer.Token) error {
+		output = append(output, fmt.Sprintf("%s %d %d:%d", tok.Get("word").Lexeme, tok.Pos, tok.Line, tok.Col))
+		if tok.Source != "" {
+			return fmt.Errorf("expected empty source, got '%s'", tok.Source)
+		}
+		return nil
+	})
+
+	if err := tokr.RunReader(iotest.OneByteReader(strings.NewReader(input))); err != nil {
+		t.Fatal(err)
+	}
+
+	if slices.Compare(expect, output) != 0 {
+		t.Errorf("expected '%s', got '%s'", strings.Join(expect, "|"), strings.Join(output, "|"))
+	}
+}
+
+// Reads "foo bar " forever.
+type endlessReader struct{ n int }
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	s := "foo bar "
+	for i := range p {
+		p[i] = s[r.n%len(s)]
+		r.n++
+	}
+	return len(p), nil
+}
+
+func TestLexReaderEndless(t *testing.T) {
+	tokr := gokenizer.New()
+	tokr.Pattern("{word}", func(t gokenizer.Token) error {
+		return nil
+	})
+
+	r := &endlessReader{}
+	lex := tokr.LexReader(r)
+
+	for i := range 10000 {
+		tok, err := lex.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expect := []string{"foo", "bar"}[i%2]; tok.Lexeme != expect {
+			t.Fatalf("expected '%s', got '%s'", expect, tok.Lexeme)
+		}
+
+		if tok.Pos != i*4 {
+			t.Fatalf("expected pos %d, got %d", i*4, tok.Pos)
+		}
+	}
+
+	// Only a small window past the last token should have been read
+	if r.n > 10000*4+8192 {
+		t.Errorf("expected less input to be read, got %d bytes", r.n)
+	}
+}
+
+func TestRunReaderError(t *testing.T) {
+	errRead := errors.New("read failed")
+
+	tokr := gokenizer.New()
+	tokr.Pattern("{word}", func(t gokenizer.Token) error {
+		return nil
+	})
+
+	r := io.MultiReader(strings.NewReader("foo bar"), iotest.ErrReader(errRead))
+	if err := tokr.RunReader(r); !errors.Is(err, errRead) {
+		t.Errorf("expected read error, got %v", err)
+	}
+}
+
+// Reads n lines of dots, the first and last starting with ERROR.
+type logReader struct {
+	n    int
+	line int
+	buf  []byte
+}
+
+func (r *logReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		if r.line == r.n {
+			return 0, io.EOF
+		}
+
+		r.buf = []byte(strings.Repeat(".", 80) + "\n")
+		if r.line == 0 || r.line == r.n-1 {
+			r.buf = append([]byte("ERROR "), r.buf...)
+		}
+		r.line++
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func TestRunReaderUnmatched(t *testing.T) {
+	tokr := gokenizer.New()
+
+	var heap uint64
+	matches := 0
+	tokr.Pattern("ERROR", func(tok gokenizer.Token) error {
+		matches++
+
+		var stats runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		heap = max(heap, stats.HeapAlloc)
+		return nil
+	})
+
+	// About 2 MB of input, where unmatched input used to be kept in memory
+	if err := tokr.RunReader(&logReader{n: 25_000}); err != nil {
+		t.Fatal(err)
+	}
+
+	if matches != 2 {
+		t.Errorf("expected 2 matches, got %d", matches)
+	}
+
+	if heap > 1<<20 {
+		t.Errorf("expected unmatched input to be discarded, heap is %d bytes", heap)
+	}
+}
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jesperkha/gokenizer"
+)
+
+type kind int
+
+const (
+	kindIdent kind = iota
+	kindNumber
+	kindKeyword
+	kindSymbol
+	kindSpace
+	kindComment
+)
+
+func TestTypedTokenizer(t *testing.T) {
+	tokr := gokenizer.NewTyped(
+		gokenizer.Rule[kind]{Pattern: "//{line}", Kind: kindComment},
+		gokenizer.Rule[kind]{Pattern: "{[ \n]+}", Kind: kindSpace},
+		gokenizer.Rule[kind]{Pattern: "{keyword}", Kind: kindKeyword},
+		gokenizer.Rule[kind]{Pattern: "{var}", Kind: kindIdent},
+		gokenizer.Rule[kind]{Pattern: "{number}", Kind: kindNumber},
+		gokenizer.Rule[kind]{Pattern: "{symbol}", Kind: kindSymbol},
+	)
+
+	tokr.Class("keyword", "var", "if")
+	tokr.Skip(kindSpace, kindComment)
+
+	tokens, err := tokr.Tokenize("// comment\nvar x = 10;")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []gokenizer.TypedToken[kind]{
+		{Token: gokenizer.Token{Lexeme: "var"}, Kind: kindKeyword},
+		{Token: gokenizer.Token{Lexeme: "x"}, Kind: kindIdent},
+		{Token: gokenizer.Token{Lexeme: "="}, Kind: kindSymbol},
+		{Token: gokenizer.Token{Lexeme: "10"}, Kind: kindNumber},
+		{Token: gokenizer.Token{Lexeme: ";"}, Kind: kindSymbol},
+	}
+
+	if len(tokens) != len(expect) {
+		t.Fatalf("expected %d tokens, got %d", len(expect), len(tokens))
+	}
+
+	for i, tok := range tokens {
+		if tok.Lexeme != expect[i].Lexeme || tok.Kind != expect[i].Kind {
+			t.Errorf("expected '%s' of kind %d, got '%s' of kind %d", expect[i].Lexeme, expect[i].Kind, tok.Lexeme, tok.Kind)
+		}
+	}
+
+	if tok := tokens[1]; tok.Line != 2 || tok.Col != 5 {
+		t.Errorf("expected 'x' at 2:5, got %d:%d", tok.Line, tok.Col)
+	}
+
+	// Errors stop tokenizing
+	tokr.Strict()
+
+	tokens, err = tokr.Tokenize("var x \x01")
+	if uerr := (&gokenizer.UnmatchedError{}); !errors.As(err, &uerr) {
+		t.Errorf("expected unmatched error, got %v", err)
+	}
+
+	if len(tokens) != 2 {
+		t.Errorf("expected the 2 tokens before the error, got %d", len(tokens))
+	}
+}
+
+func TestTypedTokenizerSamePattern(t *testing.T) {
+	tokr := gokenizer.NewTyped(
+		gokenizer.Rule[kind]{Pattern: "x", Kind: kindIdent},
+		gokenizer.Rule[kind]{Pattern: "x", Kind: kindKeyword, Priority: 5},
+	)
+
+	tokr.LongestMatch()
+
+	// The plain pattern is used first, so its tokens have no kind
+	tokr.Pattern("y", func(gokenizer.Token) error {
+		return nil
+	})
+	tokr.Rules(gokenizer.Rule[kind]{Pattern: "y", Kind: kindSymbol})
+
+	tokens, err := tokr.Tokenize("xy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tokens) != 1 || tokens[0].Kind != kindKeyword {
+		t.Errorf("expected a single keyword token, got %v", tokens)
+	}
+}
@@ -1,16 +1,43 @@
 package gokenizer
 
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jesperkha/gokenizer/stringiter"
+)
+
 type Token struct {
-	Pos    int    // Column of first character in token
+	Pos    int    // Byte offset of first character in Source
+	Line   int    // Line of first character, starting at 1
+	Col    int    // Column of first character in runes, starting at 1
 	Length int    // Length of token lexeme
 	Lexeme string // Token lexeme
-	Source string // The string provided to Run()
+	Source string // The string provided to Run(), empty for RunReader()
+
+	// The pattern that matched the token. Empty for unmatched input and
+	// class values.
+	Pattern string
+
+	// The class that matched the token, or the inline group or character
+	// set, such as "(GET|POST)". Empty for tokens matched by a whole pattern.
+	Class string
+
+	// The capture name of the class value, as in {word:name}. Empty if not
+	// given.
+	Capture string
+
+	// The class values of the match, in the order they were matched. Each
+	// repetition of a repeated class is a separate child.
+	Children []Token
 
 	matched bool
-	class   string
 
-	// Maps the class name to the parsed value, in order
-	values map[string][]Token
+	// Each match of a repeated class, nil if not repeated
+	repeats []Token
 }
 
 // Get returns the first instance of what the specified class parsed. If
@@ -19,11 +46,165 @@ func (t Token) Get(className string) Token {
 	return t.GetAt(className, 0)
 }
 
-// GetAt returns the n'th parsed string for the given class.
+// GetAt returns the n'th parsed string for the given class. The class name
+// may also be a capture name.
 func (t Token) GetAt(className string, index int) Token {
-	if l, ok := t.values[className]; ok && index < len(l) {
-		return l[index]
+	for _, child := range t.Children {
+		if !child.isNamed(className) {
+			continue
+		}
+
+		if index == 0 {
+			return child
+		}
+		index--
 	}
 
 	return Token{}
 }
+
+// Has returns true if the token has a value for the given class or capture
+// name, which tells which alternative of a class matched.
+func (t Token) Has(className string) bool {
+	return slices.ContainsFunc(t.Children, func(child Token) bool {
+		return child.isNamed(className)
+	})
+}
+
+// Returns true if the token is a value of the given class or capture name.
+func (t Token) isNamed(name string) bool {
+	return name != "" && (t.Class == name || t.Capture == name)
+}
+
+// Find returns the value at a dot separated path of class or capture names,
+// such as "expression.keyValue.key", where each name is looked up with Get().
+// Returns false if any part of the path was not matched.
+func (t Token) Find(path string) (Token, bool) {
+	for _, name := range strings.Split(path, ".") {
+		if !t.Has(name) {
+			return Token{}, false
+		}
+
+		t = t.Get(name)
+	}
+
+	return t, true
+}
+
+// FindAll returns every value of the given class or capture name in the
+// token and its children, at any depth, in the order they were matched.
+func (t Token) FindAll(className string) (tokens []Token) {
+	for _, child := range t.Children {
+		child.Walk(func(tok Token) bool {
+			if tok.isNamed(className) {
+				tokens = append(tokens, tok)
+			}
+			return true
+		})
+	}
+
+	return tokens
+}
+
+// Walk calls f for the token and then for each of its children, depth
+// first. The children of a token are skipped if f returns false for it.
+func (t Token) Walk(f func(Token) bool) {
+	if !f(t) {
+		return
+	}
+
+	for _, child := range t.Children {
+		child.Walk(f)
+	}
+}
+
+// LineCol returns the line and column of the token. If they were not set
+// when matching, see Tokenizer.LazyLineInfo(), they are computed from the
+// source string.
+func (t Token) LineCol() (line int, col int) {
+	if t.Line != 0 {
+		return t.Line, t.Col
+	}
+
+	iter := stringiter.New(t.Source)
+	return iter.LineColAt(t.Pos)
+}
+
+// Sets the line and column of the token and all its children.
+func (t *Token) setLineInfo(iter *stringiter.StringIter) {
+	t.Line, t.Col = iter.LineColAt(t.Pos)
+
+	for i := range t.Children {
+		t.Children[i].setLineInfo(iter)
+	}
+}
+
+// The JSON form of a token, see Token.MarshalJSON().
+type tokenJSON struct {
+	Class    string   `json:"class,omitempty"`
+	Capture  string   `json:"capture,omitempty"`
+	Pattern  string   `json:"pattern,omitempty"`
+	Lexeme   string   `json:"lexeme"`
+	Span     spanJSON `json:"span"`
+	Children []Token  `json:"children,omitempty"`
+}
+
+type spanJSON struct {
+	Pos    int `json:"pos"`
+	Length int `json:"length"`
+	Line   int `json:"line"`
+	Col    int `json:"col"`
+}
+
+// MarshalJSON encodes the token as an object with its class, capture name,
+// pattern, lexeme, span and children. Empty names and children are left out.
+// The source string is not included.
+func (t Token) MarshalJSON() ([]byte, error) {
+	line, col := t.LineCol()
+
+	return json.Marshal(tokenJSON{
+		Class:    t.Class,
+		Capture:  t.Capture,
+		Pattern:  t.Pattern,
+		Lexeme:   t.Lexeme,
+		Span:     spanJSON{Pos: t.Pos, Length: t.Length, Line: line, Col: col},
+		Children: t.Children,
+	})
+}
+
+// Dump returns the token and its children as an indented tree, one token
+// per line. Each line has the class and capture name, or the pattern for
+// the root token, followed by the quoted lexeme and the line and column:
+//
+//	{pair}, {number} "a=1, 2" 1:1
+//	  pair "a=1" 1:1
+//	    word:key "a" 1:1
+//	    number "1" 1:3
+//	  number "2" 1:6
+func (t Token) Dump() string {
+	sb := strings.Builder{}
+	t.dump(&sb, 0)
+	return sb.String()
+}
+
+func (t Token) dump(sb *strings.Builder, depth int) {
+	name := t.Class
+	if t.Capture != "" {
+		name += ":" + t.Capture
+	}
+
+	if name == "" {
+		name = t.Pattern
+	}
+
+	if name == "" {
+		name = "(unmatched)"
+	}
+
+	line, col := t.LineCol()
+	fmt.Fprintf(sb, "%s%s %s %d:%d\n", strings.Repeat("  ", depth), name, strconv.Quote(t.Lexeme), line, col)
+
+	for _, child := range t.Children {
+		child.dump(sb, depth+1)
+	}
+}
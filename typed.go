@@ -0,0 +1,89 @@
+package gokenizer
+
+import "io"
+
+// Rule is a pattern producing tokens of a user defined kind. See
+// TypedTokenizer.
+type Rule[K comparable] struct {
+	Pattern  string
+	Kind     K
+	Priority int // See Tokenizer.PatternPriority()
+}
+
+// TypedToken is a token with the kind of the rule that matched it.
+type TypedToken[K comparable] struct {
+	Token
+	Kind K
+}
+
+// TypedTokenizer is a tokenizer returning the matched tokens as a slice
+// instead of calling callbacks. Each pattern is added as a rule with a kind.
+// Classes and options are set on the embedded Tokenizer as usual.
+type TypedTokenizer[K comparable] struct {
+	Tokenizer
+	kinds map[int]K // Kinds of the rules by their pattern index
+	skip  map[K]bool
+}
+
+// NewTyped returns a typed tokenizer with the given rules.
+func NewTyped[K comparable](rules ...Rule[K]) *TypedTokenizer[K] {
+	t := &TypedTokenizer[K]{
+		Tokenizer: New(),
+		kinds:     make(map[int]K),
+		skip:      make(map[K]bool),
+	}
+
+	t.Rules(rules...)
+	return t
+}
+
+// Rules adds patterns producing tokens of the given kinds. Rules are
+// matched by the order they are added in, like Tokenizer.Pattern().
+func (t *TypedTokenizer[K]) Rules(rules ...Rule[K]) {
+	for _, r := range rules {
+		n := len(t.patterns)
+		t.PatternPriority(r.Pattern, r.Priority, func(Token) error {
+			return nil
+		})
+
+		if len(t.patterns) > n {
+			t.kinds[n] = r.Kind
+		}
+	}
+}
+
+// Skip leaves tokens of the given kinds, such as whitespace and comments,
+// out of the result of Tokenize().
+func (t *TypedTokenizer[K]) Skip(kinds ...K) {
+	for _, kind := range kinds {
+		t.skip[kind] = true
+	}
+}
+
+// Tokenize returns all tokens in s matched by a rule, in order. Tokens of
+// skipped kinds are left out. Callbacks of patterns added with Pattern()
+// are still called, but their tokens are not returned. Returns the first
+// error, along with the tokens before it.
+func (t *TypedTokenizer[K]) Tokenize(s string) ([]TypedToken[K], error) {
+	tokens := []TypedToken[K]{}
+
+	lex := t.Lex(s)
+
+	for {
+		tok, idx, err := lex.next()
+		if err == io.EOF {
+			return tokens, nil
+		}
+
+		if err != nil {
+			return tokens, err
+		}
+
+		kind, ok := t.kinds[idx]
+		if !ok || t.skip[kind] {
+			continue
+		}
+
+		tokens = append(tokens, TypedToken[K]{Token: tok, Kind: kind})
+	}
+}